@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fleet-pulse-users-service/internal/db"
+	"fleet-pulse-users-service/internal/repositories"
+	"fleet-pulse-users-service/internal/services"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const usage = `Usage: admin <command> [flags]
+
+Commands:
+  rotate-keys     Activate a new token signing key and retire the current one
+  list-keys       List token signing keys
+  create-client   Register an OAuth client
+                  -name <name> -scopes "<scope> ..." [-company <company id>]
+                  [-redirect-uris "<uri> ..."] [-public]
+  list-clients    List OAuth clients
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	databaseConnection := db.DatabaseConnection()
+
+	switch os.Args[1] {
+	case "rotate-keys":
+		keyRing, err := services.InitSigningKeys(databaseConnection)
+		if err != nil {
+			log.Fatalf("Failed to load signing keys: %v", err)
+		}
+		key, err := keyRing.Rotate()
+		if err != nil {
+			log.Fatalf("Failed to rotate signing keys: %v", err)
+		}
+		fmt.Printf("Activated signing key %s\n", key.KID)
+	case "list-keys":
+		keys, err := repositories.NewSigningKeyRepository(databaseConnection).List()
+		if err != nil {
+			log.Fatalf("Failed to list signing keys: %v", err)
+		}
+		for _, key := range keys {
+			expiresAt := "-"
+			if key.ExpiresAt != nil {
+				expiresAt = key.ExpiresAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\n", key.KID, key.Status, key.CreatedAt.Format(time.RFC3339), expiresAt)
+		}
+	case "create-client":
+		flags := flag.NewFlagSet("create-client", flag.ExitOnError)
+		name := flags.String("name", "", "client name")
+		scopes := flags.String("scopes", "", "space-separated scopes the client may request")
+		company := flags.String("company", "", "ID of the company the client is bound to")
+		redirectURIs := flags.String("redirect-uris", "", "space-separated redirect URIs for the authorization code grant")
+		public := flags.Bool("public", false, "create a public client, such as a mobile app, without a secret")
+		_ = flags.Parse(os.Args[2:])
+		if *name == "" {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+
+		var companyID *uuid.UUID
+		if *company != "" {
+			id, err := uuid.Parse(*company)
+			if err != nil {
+				log.Fatalf("Invalid company ID: %v", err)
+			}
+			companyID = &id
+		}
+		client, secret, err := services.NewClientService(databaseConnection).CreateClient(services.ClientRegistration{
+			Name:         *name,
+			Scopes:       strings.Fields(*scopes),
+			RedirectURIs: strings.Fields(*redirectURIs),
+			Public:       *public,
+			CompanyID:    companyID,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create client: %v", err)
+		}
+		fmt.Printf("client_id:     %s\n", client.ClientID)
+		if !client.Public {
+			fmt.Printf("client_secret: %s\n", secret)
+			fmt.Println("The secret is not stored and cannot be shown again.")
+		}
+	case "list-clients":
+		clients, err := services.NewClientService(databaseConnection).ListClients()
+		if err != nil {
+			log.Fatalf("Failed to list clients: %v", err)
+		}
+		for _, client := range clients {
+			companyID := "-"
+			if client.CompanyID != nil {
+				companyID = client.CompanyID.String()
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\n", client.ClientID, client.Name, companyID, client.Scopes)
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"fleet-pulse-users-service/internal/api"
 	"fleet-pulse-users-service/internal/config"
 	"fleet-pulse-users-service/internal/db"
+	"fleet-pulse-users-service/internal/mailer"
+	"fleet-pulse-users-service/internal/services"
 	"log"
 	"net/http"
 	"os"
@@ -35,15 +37,37 @@ func main() {
 	cfg := config.Get()
 
 	databaseConnection := db.DatabaseConnection()
+	if _, err := services.InitSigningKeys(databaseConnection); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	if _, err := services.InitTokenRevocations(databaseConnection); err != nil {
+		log.Fatalf("Failed to load token revocations: %v", err)
+	}
+
+	dispatcherCtx, stopDispatcher := context.WithCancel(context.Background())
+	dispatcherDone := make(chan struct{})
+	go func() {
+		defer close(dispatcherDone)
+		services.NewOutboxDispatcher(databaseConnection, mailer.Get()).Run(dispatcherCtx)
+	}()
 
 	router := gin.Default()
 	v1Group := router.Group("/v1")
 	docs.SwaggerInfo.BasePath = ""
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 	api.AddHealthRoutes(router, databaseConnection)
+	api.AddWellKnownRoutes(router)
 
 	api.AddUserRoutes(v1Group, databaseConnection)
 	api.AddAuthRoutes(v1Group, databaseConnection)
+	api.AddCompanyRoutes(v1Group, databaseConnection)
+	api.AddRoleRoutes(v1Group, databaseConnection)
+	api.AddSessionRoutes(v1Group, databaseConnection)
+	api.AddInviteRoutes(v1Group, databaseConnection)
+	api.AddPasswordRoutes(v1Group, databaseConnection)
+	api.AddMFARoutes(v1Group, databaseConnection)
+	api.AddPasskeyRoutes(v1Group, databaseConnection)
+	api.AddOAuthRoutes(v1Group, databaseConnection)
 
 	server := &http.Server{
 		Addr:    cfg.Server.Port,
@@ -68,4 +92,8 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatal("Server forced to shutdown:", err)
 	}
+
+	// Let the dispatcher finish the batch it is sending.
+	stopDispatcher()
+	<-dispatcherDone
 }
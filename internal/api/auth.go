@@ -2,9 +2,11 @@ package api
 
 import (
 	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/middlewares"
 	"fleet-pulse-users-service/internal/schemas"
 	"fleet-pulse-users-service/internal/services"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -12,12 +14,14 @@ import (
 
 // LoginUserHandler godoc
 // @Summary Login User
-// @Description Login User
+// @Description Login User. Users with two-factor authentication get a challenge instead of tokens,
+// @Description to be completed at /v1/login/mfa.
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param user body schemas.LoginUserRequest true "Login Data"
-// @Success 200 {object} schemas.LoginResponse
+// @Success 201 {object} schemas.LoginResponse
+// @Success 200 {object} schemas.MFAChallengeResponse
 // @Failure 400 {object} schemas.ErrorResponse
 // @Failure 409 {object} schemas.ErrorResponse
 // @Failure 500 {object} schemas.ErrorResponse
@@ -30,12 +34,121 @@ func LoginUserHandler(authService *services.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		accessToken, refreshToken, err := authService.LoginUser(req)
+		accessToken, refreshToken, challenge, err := authService.LoginUser(req, clientInfo(ctx, req.DeviceName))
 		if err != nil {
 			errors.HandleAuthErrors(ctx, err)
 			ctx.Error(err)
 			return
 		}
+		if challenge != nil {
+			ctx.JSON(http.StatusOK, schemas.MFAChallengeResponse{
+				MFARequired:    true,
+				ChallengeToken: challenge.Token,
+				ExpiresAt:      challenge.ExpiresAt,
+			})
+			return
+		}
+		ctx.JSON(
+			http.StatusCreated,
+			schemas.LoginResponse{Token: accessToken, RefreshToken: refreshToken},
+		)
+	}
+}
+
+// MFALoginHandler godoc
+// @Summary Complete two-factor login
+// @Description Exchange the challenge from /v1/login and a TOTP or recovery code for the token pair.
+// @Description The challenge expires after a few minutes or a few wrong codes.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param login body schemas.MFALoginRequest true "Challenge and code"
+// @Success 201 {object} schemas.LoginResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Failure 429 {object} schemas.ErrorResponse
+// @Router /v1/login/mfa [post]
+func MFALoginHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req schemas.MFALoginRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		accessToken, refreshToken, err := authService.LoginWithMFA(req.ChallengeToken, req.Code, clientInfo(ctx, req.DeviceName))
+		if err != nil {
+			errors.HandleMFAErrors(ctx, err)
+			ctx.Error(err)
+			return
+		}
+		ctx.JSON(
+			http.StatusCreated,
+			schemas.LoginResponse{Token: accessToken, RefreshToken: refreshToken},
+		)
+	}
+}
+
+// BeginPasskeyLoginHandler godoc
+// @Summary Begin passkey login
+// @Description Get the options for navigator.credentials.get to sign in with a passkey instead of a password.
+// @Description The email is optional, without it the authenticator offers the passkeys it keeps for this service.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param login body schemas.PasskeyLoginBeginRequest false "Email and device name"
+// @Success 200 {object} schemas.PasskeyCeremonyResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/login/passkey/begin [post]
+func BeginPasskeyLoginHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req schemas.PasskeyLoginBeginRequest
+		if ctx.Request.ContentLength != 0 {
+			if err := ctx.ShouldBindJSON(&req); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		ceremony, err := authService.BeginPasskeyLogin(req.Email, req.DeviceName)
+		if err != nil {
+			errors.HandleWebAuthnErrors(ctx, err)
+			ctx.Error(err)
+			return
+		}
+		ctx.JSON(http.StatusOK, toPasskeyCeremonyResponse(ceremony))
+	}
+}
+
+// FinishPasskeyLoginHandler godoc
+// @Summary Finish passkey login
+// @Description Exchange the credential returned by navigator.credentials.get for the token pair.
+// @Description The authenticator verifies the user, so no two-factor code is asked for.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param login body schemas.PasskeyLoginFinishRequest true "Session and credential"
+// @Success 201 {object} schemas.LoginResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/login/passkey/finish [post]
+func FinishPasskeyLoginHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req schemas.PasskeyLoginFinishRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		accessToken, refreshToken, err := authService.LoginWithPasskey(req.SessionID, req.Credential, clientInfo(ctx, req.DeviceName))
+		if err != nil {
+			errors.HandleWebAuthnErrors(ctx, err)
+			ctx.Error(err)
+			return
+		}
 		ctx.JSON(
 			http.StatusCreated,
 			schemas.LoginResponse{Token: accessToken, RefreshToken: refreshToken},
@@ -45,7 +158,8 @@ func LoginUserHandler(authService *services.AuthService) gin.HandlerFunc {
 
 // RefreshTokenHandler godoc
 // @Summary Refresh Access Token
-// @Description Refresh Access Token using a valid refresh token
+// @Description Refresh Access Token using a valid refresh token.
+// @Description The refresh token is rotated; replaying a used one revokes every token issued from the same login.
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -53,6 +167,7 @@ func LoginUserHandler(authService *services.AuthService) gin.HandlerFunc {
 // @Success 200 {object} schemas.LoginResponse
 // @Failure 400 {object} schemas.ErrorResponse
 // @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
 // @Failure 500 {object} schemas.ErrorResponse
 // @Router /v1/refresh [post]
 func RefreshTokenHandler(authService *services.AuthService) gin.HandlerFunc {
@@ -63,7 +178,7 @@ func RefreshTokenHandler(authService *services.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		accessToken, refreshToken, err := authService.RefreshAccessToken(req.RefreshToken)
+		accessToken, refreshToken, err := authService.RefreshAccessToken(req.RefreshToken, clientInfo(ctx, ""))
 		if err != nil {
 			errors.HandleAuthErrors(ctx, err)
 			return
@@ -76,10 +191,85 @@ func RefreshTokenHandler(authService *services.AuthService) gin.HandlerFunc {
 	}
 }
 
+// LogoutHandler godoc
+// @Summary Logout
+// @Description End the session the refresh token belongs to. An access token sent along in the Authorization header is revoked as well
+// @Tags Auth
+// @Accept json
+// @Param token body schemas.RefreshTokenRequest true "Refresh Token"
+// @Success 204
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/logout [post]
+func LogoutHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req schemas.RefreshTokenRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := authService.Logout(req.RefreshToken, bearerToken(ctx)); err != nil {
+			errors.HandleAuthErrors(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// LogoutAllHandler godoc
+// @Summary Logout everywhere
+// @Description End every session of the current user
+// @Tags Auth
+// @Success 204
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/logout/all [post]
+// @Security Bearer
+func LogoutAllHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := middlewares.CurrentUserID(ctx)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if err := authService.LogoutAll(userID); err != nil {
+			errors.HandleAuthErrors(ctx, err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// bearerToken returns the access token from the Authorization header, if one was sent.
+func bearerToken(ctx *gin.Context) string {
+	parts := strings.SplitN(ctx.GetHeader("Authorization"), " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return ""
+	}
+	return parts[1]
+}
+
+func clientInfo(ctx *gin.Context, deviceName string) services.ClientInfo {
+	return services.ClientInfo{
+		DeviceName: deviceName,
+		UserAgent:  ctx.Request.UserAgent(),
+		IPAddress:  ctx.ClientIP(),
+	}
+}
+
 func AddAuthRoutes(router *gin.RouterGroup, db *gorm.DB) *gin.RouterGroup {
 	authService := services.NewAuthService(db)
 
 	router.POST("/login", LoginUserHandler(authService))
+	router.POST("/login/mfa", MFALoginHandler(authService))
+	router.POST("/login/passkey/begin", BeginPasskeyLoginHandler(authService))
+	router.POST("/login/passkey/finish", FinishPasskeyLoginHandler(authService))
 	router.POST("/refresh", RefreshTokenHandler(authService))
+	router.POST("/logout", LogoutHandler(authService))
+	router.POST("/logout/all", middlewares.JWTAuthMiddleware(authService), LogoutAllHandler(authService))
 	return router
 }
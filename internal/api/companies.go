@@ -0,0 +1,314 @@
+package api
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/middlewares"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateCompanyHandler godoc
+// @Summary Create company
+// @Description Create a new company and make the current user its first member and fleet admin.
+// @Description Refresh the access token afterwards to pick up the new company and role.
+// @Tags Companies
+// @Accept json
+// @Produce json
+// @Param company body schemas.CreateCompanyRequest true "Company data"
+// @Success 201 {object} schemas.CompanyResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 409 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/companies [post]
+// @Security Bearer
+func CreateCompanyHandler(companyServiceConstructor func(db *gorm.DB) *services.CompanyService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		var req schemas.CreateCompanyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		companyService := companyServiceConstructor(tx)
+		company, err := companyService.CreateCompany(userID, req)
+		if err != nil {
+			errors.HandleCompanyErrors(c, err)
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, toCompanyResponse(company))
+	}
+}
+
+// ListCompaniesHandler godoc
+// @Summary List companies
+// @Description List companies
+// @Tags Companies
+// @Produce json
+// @Success 200 {array} schemas.CompanyResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/companies [get]
+// @Security Bearer
+func ListCompaniesHandler(companyServiceConstructor func(db *gorm.DB) *services.CompanyService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		companyService := companyServiceConstructor(tx)
+		companies, err := companyService.ListCompanies()
+		if err != nil {
+			errors.HandleCompanyErrors(c, err)
+			return
+		}
+
+		response := make([]schemas.CompanyResponse, 0, len(companies))
+		for i := range companies {
+			response = append(response, toCompanyResponse(&companies[i]))
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// GetCompanyHandler godoc
+// @Summary Get company
+// @Description Get a company by ID
+// @Tags Companies
+// @Produce json
+// @Param id path string true "Company ID"
+// @Success 200 {object} schemas.CompanyResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Router /v1/companies/{id} [get]
+// @Security Bearer
+func GetCompanyHandler(companyServiceConstructor func(db *gorm.DB) *services.CompanyService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		companyID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		companyService := companyServiceConstructor(tx)
+		company, err := companyService.GetCompanyById(companyID)
+		if err != nil {
+			errors.HandleCompanyErrors(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, toCompanyResponse(company))
+	}
+}
+
+// UpdateCompanyHandler godoc
+// @Summary Update company
+// @Description Update company details
+// @Tags Companies
+// @Accept json
+// @Produce json
+// @Param id path string true "Company ID"
+// @Param company body schemas.UpdateCompanyRequest true "Company data"
+// @Success 200 {object} schemas.CompanyResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/companies/{id} [patch]
+// @Security Bearer
+func UpdateCompanyHandler(companyServiceConstructor func(db *gorm.DB) *services.CompanyService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		companyID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		var req schemas.UpdateCompanyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		companyService := companyServiceConstructor(tx)
+		company, err := companyService.UpdateCompany(companyID, req)
+		if err != nil {
+			errors.HandleCompanyErrors(c, err)
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, toCompanyResponse(company))
+	}
+}
+
+// DeleteCompanyHandler godoc
+// @Summary Delete company
+// @Description Delete a company. Its members are detached from it, lose their roles and are signed out.
+// @Tags Companies
+// @Param id path string true "Company ID"
+// @Success 204
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/companies/{id} [delete]
+// @Security Bearer
+func DeleteCompanyHandler(companyServiceConstructor func(db *gorm.DB) *services.CompanyService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		companyID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		companyService := companyServiceConstructor(tx)
+		if err := companyService.DeleteCompany(companyID); err != nil {
+			errors.HandleCompanyErrors(c, err)
+			c.Error(err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ListCompanyMembersHandler godoc
+// @Summary List company members
+// @Description List users that belong to a company
+// @Tags Companies
+// @Produce json
+// @Param id path string true "Company ID"
+// @Success 200 {array} schemas.UserResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Router /v1/companies/{id}/members [get]
+// @Security Bearer
+func ListCompanyMembersHandler(companyServiceConstructor func(db *gorm.DB) *services.CompanyService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		companyID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		companyService := companyServiceConstructor(tx)
+		members, err := companyService.ListMembers(companyID)
+		if err != nil {
+			errors.HandleCompanyErrors(c, err)
+			return
+		}
+
+		response := make([]schemas.UserResponse, 0, len(members))
+		for i := range members {
+			response = append(response, toUserResponse(&members[i]))
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// RemoveCompanyMemberHandler godoc
+// @Summary Remove company member
+// @Description Detach a user from the company
+// @Tags Companies
+// @Param id path string true "Company ID"
+// @Param user_id path string true "User ID"
+// @Success 204
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/companies/{id}/members/{user_id} [delete]
+// @Security Bearer
+func RemoveCompanyMemberHandler(companyServiceConstructor func(db *gorm.DB) *services.CompanyService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		companyID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+		userID, ok := parseUUIDParam(c, "user_id")
+		if !ok {
+			return
+		}
+
+		companyService := companyServiceConstructor(tx)
+		if err := companyService.RemoveMember(companyID, userID); err != nil {
+			errors.HandleCompanyErrors(c, err)
+			c.Error(err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func toCompanyResponse(company *models.Company) schemas.CompanyResponse {
+	return schemas.CompanyResponse{
+		ID:        company.ID,
+		Name:      company.Name,
+		CreatedAt: company.CreatedAt,
+	}
+}
+
+func parseUUIDParam(c *gin.Context, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func AddCompanyRoutes(router *gin.RouterGroup, db *gorm.DB) *gin.RouterGroup {
+	companyServiceConstructor := func(db *gorm.DB) *services.CompanyService {
+		return services.NewCompanyService(db)
+	}
+
+	companies := router.Group("/companies", middlewares.JWTAuthMiddleware(services.NewAuthService(db)))
+
+	companies.POST("",
+		internal.TransactionalHandler(db, CreateCompanyHandler(companyServiceConstructor)),
+	)
+	companies.GET("",
+		middlewares.RequirePermission("companies:read"),
+		internal.TransactionalHandler(db, ListCompaniesHandler(companyServiceConstructor)),
+	)
+	companies.GET("/:id",
+		middlewares.RequirePermission("companies:read"),
+		internal.TransactionalHandler(db, GetCompanyHandler(companyServiceConstructor)),
+	)
+	companies.PATCH("/:id",
+		middlewares.RequirePermission("companies:write"),
+		internal.TransactionalHandler(db, UpdateCompanyHandler(companyServiceConstructor)),
+	)
+	companies.DELETE("/:id",
+		middlewares.RequirePermission("companies:write"),
+		internal.TransactionalHandler(db, DeleteCompanyHandler(companyServiceConstructor)),
+	)
+
+	companies.GET("/:id/members",
+		middlewares.RequirePermission("users:read"),
+		internal.TransactionalHandler(db, ListCompanyMembersHandler(companyServiceConstructor)),
+	)
+	companies.DELETE("/:id/members/:user_id",
+		middlewares.RequirePermission("users:write"),
+		internal.TransactionalHandler(db, RemoveCompanyMemberHandler(companyServiceConstructor)),
+	)
+
+	return router
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/middlewares"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateInviteHandler godoc
+// @Summary Invite user
+// @Description Create a company user with a role and email them an invite to set their password
+// @Tags Invites
+// @Accept json
+// @Produce json
+// @Param id path string true "Company ID"
+// @Param invite body schemas.InviteUserRequest true "Invited user"
+// @Success 201 {object} schemas.InviteResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 409 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/companies/{id}/invites [post]
+// @Security Bearer
+func CreateInviteHandler(inviteServiceConstructor func(db *gorm.DB) *services.InviteService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		companyID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+		var req schemas.InviteUserRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		invitedByID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		inviteService := inviteServiceConstructor(tx)
+		invite, err := inviteService.Invite(companyID, &invitedByID, req)
+		if err != nil {
+			errors.HandleInviteErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusCreated, toInviteResponse(invite))
+	}
+}
+
+// ListInvitesHandler godoc
+// @Summary List company invites
+// @Description List invites of the company, pending ones unless another status is asked for
+// @Tags Invites
+// @Produce json
+// @Param id path string true "Company ID"
+// @Param status query string false "pending, accepted, revoked, expired or all"
+// @Success 200 {array} schemas.InviteResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/companies/{id}/invites [get]
+// @Security Bearer
+func ListInvitesHandler(inviteServiceConstructor func(db *gorm.DB) *services.InviteService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		companyID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		inviteService := inviteServiceConstructor(tx)
+		invites, err := inviteService.List(companyID, c.Query("status"))
+		if err != nil {
+			errors.HandleInviteErrors(c, err)
+			c.Error(err)
+			return
+		}
+
+		response := make([]schemas.InviteResponse, 0, len(invites))
+		for i := range invites {
+			response = append(response, toInviteResponse(&invites[i]))
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// ResendInviteHandler godoc
+// @Summary Resend invite
+// @Description Email the invited user a new link. The previous link stops working.
+// @Tags Invites
+// @Produce json
+// @Param id path string true "Company ID"
+// @Param invite_id path string true "Invite ID"
+// @Success 201 {object} schemas.InviteResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 409 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/companies/{id}/invites/{invite_id}/resend [post]
+// @Security Bearer
+func ResendInviteHandler(inviteServiceConstructor func(db *gorm.DB) *services.InviteService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		companyID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+		inviteID, ok := parseUUIDParam(c, "invite_id")
+		if !ok {
+			return
+		}
+		invitedByID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		inviteService := inviteServiceConstructor(tx)
+		invite, err := inviteService.Resend(companyID, inviteID, &invitedByID)
+		if err != nil {
+			errors.HandleInviteErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusCreated, toInviteResponse(invite))
+	}
+}
+
+// RevokeInviteHandler godoc
+// @Summary Revoke invite
+// @Description Make a pending invite unusable
+// @Tags Invites
+// @Param id path string true "Company ID"
+// @Param invite_id path string true "Invite ID"
+// @Success 204
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 409 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/companies/{id}/invites/{invite_id} [delete]
+// @Security Bearer
+func RevokeInviteHandler(inviteServiceConstructor func(db *gorm.DB) *services.InviteService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		companyID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+		inviteID, ok := parseUUIDParam(c, "invite_id")
+		if !ok {
+			return
+		}
+
+		inviteService := inviteServiceConstructor(tx)
+		if err := inviteService.Revoke(companyID, inviteID); err != nil {
+			errors.HandleInviteErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func toInviteResponse(invite *models.Invite) schemas.InviteResponse {
+	return schemas.InviteResponse{
+		ID:          invite.ID,
+		UserID:      invite.UserID,
+		Email:       invite.Email,
+		CompanyID:   invite.CompanyID,
+		InvitedByID: invite.InvitedByID,
+		Status:      invite.Status,
+		ExpiresAt:   invite.ExpiresAt,
+		AcceptedAt:  invite.AcceptedAt,
+		RevokedAt:   invite.RevokedAt,
+		CreatedAt:   invite.CreatedAt,
+	}
+}
+
+func AddInviteRoutes(router *gin.RouterGroup, db *gorm.DB) *gin.RouterGroup {
+	inviteServiceConstructor := func(db *gorm.DB) *services.InviteService {
+		return services.NewInviteService(db)
+	}
+
+	invites := router.Group("/companies/:id/invites", middlewares.JWTAuthMiddleware(services.NewAuthService(db)))
+
+	invites.POST("",
+		middlewares.RequirePermission("users:write"),
+		internal.TransactionalHandler(db, CreateInviteHandler(inviteServiceConstructor)),
+	)
+	invites.GET("",
+		middlewares.RequirePermission("users:read"),
+		internal.TransactionalHandler(db, ListInvitesHandler(inviteServiceConstructor)),
+	)
+	invites.POST("/:invite_id/resend",
+		middlewares.RequirePermission("users:write"),
+		internal.TransactionalHandler(db, ResendInviteHandler(inviteServiceConstructor)),
+	)
+	invites.DELETE("/:invite_id",
+		middlewares.RequirePermission("users:write"),
+		internal.TransactionalHandler(db, RevokeInviteHandler(inviteServiceConstructor)),
+	)
+
+	return router
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/middlewares"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetMFAStatusHandler godoc
+// @Summary Two-factor authentication status
+// @Description Whether the current user has two-factor authentication and how many recovery codes are left
+// @Tags MFA
+// @Produce json
+// @Success 200 {object} schemas.MFAStatusResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/users/current/mfa [get]
+// @Security Bearer
+func GetMFAStatusHandler(mfaServiceConstructor func(db *gorm.DB) *services.MFAService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		mfaService := mfaServiceConstructor(tx)
+		enabled, remaining, err := mfaService.Status(userID)
+		if err != nil {
+			errors.HandleMFAErrors(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, schemas.MFAStatusResponse{TOTPEnabled: enabled, RecoveryCodesRemaining: remaining})
+	}
+}
+
+// StartTOTPEnrollmentHandler godoc
+// @Summary Start TOTP enrollment
+// @Description Generate an authenticator app secret for the current user. Two-factor authentication is turned on once a first code is confirmed.
+// @Tags MFA
+// @Produce json
+// @Success 201 {object} schemas.TOTPEnrollmentResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 409 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/users/current/mfa/totp [post]
+// @Security Bearer
+func StartTOTPEnrollmentHandler(mfaServiceConstructor func(db *gorm.DB) *services.MFAService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		mfaService := mfaServiceConstructor(tx)
+		enrollment, err := mfaService.StartTOTPEnrollment(userID)
+		if err != nil {
+			errors.HandleMFAErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusCreated, schemas.TOTPEnrollmentResponse{
+			Secret:     enrollment.Secret,
+			OTPAuthURI: enrollment.URI,
+			QRCodePNG:  enrollment.QRCodePNG,
+		})
+	}
+}
+
+// ConfirmTOTPHandler godoc
+// @Summary Confirm TOTP enrollment
+// @Description Turn on two-factor authentication with a first code from the authenticator app. The recovery codes are only shown in this response.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param code body schemas.MFACodeRequest true "TOTP code"
+// @Success 200 {object} schemas.RecoveryCodesResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 409 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/users/current/mfa/totp/confirm [post]
+// @Security Bearer
+func ConfirmTOTPHandler(mfaServiceConstructor func(db *gorm.DB) *services.MFAService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		var req schemas.MFACodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		mfaService := mfaServiceConstructor(tx)
+		codes, err := mfaService.ConfirmTOTP(userID, req.Code)
+		if err != nil {
+			errors.HandleMFAErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, schemas.RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// DisableTOTPHandler godoc
+// @Summary Disable TOTP
+// @Description Turn off two-factor authentication for the current user with a TOTP or recovery code
+// @Tags MFA
+// @Accept json
+// @Param code body schemas.MFACodeRequest true "TOTP or recovery code"
+// @Success 204
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Failure 429 {object} schemas.ErrorResponse
+// @Router /v1/users/current/mfa/totp [delete]
+// @Security Bearer
+func DisableTOTPHandler(mfaServiceConstructor func(db *gorm.DB) *services.MFAService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		var req schemas.MFACodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		mfaService := mfaServiceConstructor(tx)
+		if err := mfaService.DisableTOTP(userID, req.Code); err != nil {
+			errors.HandleMFAErrors(c, err)
+			// A wrong code is counted towards the lockout, which must not be rolled back.
+			if !errors.IsCountedMFAFailure(err) {
+				c.Error(err)
+			}
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// RegenerateRecoveryCodesHandler godoc
+// @Summary Regenerate recovery codes
+// @Description Replace every recovery code of the current user. The new codes are only shown in this response.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param code body schemas.MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} schemas.RecoveryCodesResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Failure 429 {object} schemas.ErrorResponse
+// @Router /v1/users/current/mfa/recovery-codes [post]
+// @Security Bearer
+func RegenerateRecoveryCodesHandler(mfaServiceConstructor func(db *gorm.DB) *services.MFAService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		var req schemas.MFACodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		mfaService := mfaServiceConstructor(tx)
+		codes, err := mfaService.RegenerateRecoveryCodes(userID, req.Code)
+		if err != nil {
+			errors.HandleMFAErrors(c, err)
+			// A wrong code is counted towards the lockout, which must not be rolled back.
+			if !errors.IsCountedMFAFailure(err) {
+				c.Error(err)
+			}
+			return
+		}
+		c.JSON(http.StatusOK, schemas.RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+func AddMFARoutes(router *gin.RouterGroup, db *gorm.DB) *gin.RouterGroup {
+	mfaServiceConstructor := func(db *gorm.DB) *services.MFAService {
+		return services.NewMFAService(db)
+	}
+
+	mfa := router.Group("/users/current/mfa", middlewares.JWTAuthMiddleware(services.NewAuthService(db)))
+
+	mfa.GET("",
+		internal.TransactionalHandler(db, GetMFAStatusHandler(mfaServiceConstructor)),
+	)
+	mfa.POST("/totp",
+		internal.TransactionalHandler(db, StartTOTPEnrollmentHandler(mfaServiceConstructor)),
+	)
+	mfa.POST("/totp/confirm",
+		internal.TransactionalHandler(db, ConfirmTOTPHandler(mfaServiceConstructor)),
+	)
+	mfa.DELETE("/totp",
+		internal.TransactionalHandler(db, DisableTOTPHandler(mfaServiceConstructor)),
+	)
+	mfa.POST("/recovery-codes",
+		internal.TransactionalHandler(db, RegenerateRecoveryCodesHandler(mfaServiceConstructor)),
+	)
+
+	return router
+}
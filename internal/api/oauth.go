@@ -0,0 +1,246 @@
+package api
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/middlewares"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/services"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// IntrospectTokenHandler godoc
+// @Summary Introspect token
+// @Description Report whether an access or refresh token is active, following RFC 7662. Unknown, expired, revoked and other companies' tokens are all reported inactive.
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Access or refresh token"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Success 200 {object} schemas.IntrospectionResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Router /v1/oauth/introspect [post]
+// @Security Bearer
+func IntrospectTokenHandler(oauthServiceConstructor func(db *gorm.DB) *services.OAuthService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		var req schemas.IntrospectionRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		oauthService := oauthServiceConstructor(tx)
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, oauthService.Introspect(req.Token, req.TokenTypeHint))
+	}
+}
+
+// AuthorizationInfoHandler godoc
+// @Summary Validate authorization request
+// @Description Check an authorization code request (RFC 6749 with PKCE S256) and describe the client and scopes, for the sign-in and consent screen
+// @Tags OAuth
+// @Produce json
+// @Param response_type query string true "Response type" Enums(code)
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "Registered redirect URI"
+// @Param scope query string false "Space-separated scopes, defaults to every scope of the client"
+// @Param state query string false "Opaque value returned with the code"
+// @Param code_challenge query string true "PKCE code challenge"
+// @Param code_challenge_method query string true "PKCE method" Enums(S256)
+// @Success 200 {object} schemas.AuthorizationInfoResponse
+// @Failure 400 {object} schemas.OAuthErrorResponse
+// @Failure 401 {object} schemas.OAuthErrorResponse
+// @Failure 500 {object} schemas.OAuthErrorResponse
+// @Router /v1/oauth/authorize [get]
+func AuthorizationInfoHandler(oauthService *services.OAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req schemas.AuthorizationRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			errors.HandleOAuthErrors(c, fmt.Errorf("%w: %s", errors.ErrInvalidRequest, err))
+			return
+		}
+
+		client, scopes, err := oauthService.ValidateAuthorizationRequest(req)
+		if err != nil {
+			errors.HandleOAuthErrors(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, schemas.AuthorizationInfoResponse{
+			ClientID:    client.ClientID,
+			ClientName:  client.Name,
+			RedirectURI: req.RedirectURI,
+			Scopes:      scopes,
+		})
+	}
+}
+
+// AuthorizeHandler godoc
+// @Summary Authorize client
+// @Description Sign the user in, record their consent and issue an authorization code. Returns the URI to send the user agent back to, carrying the code or an access_denied error.
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Param request body schemas.AuthorizeRequest true "Authorization request, credentials and consent"
+// @Success 200 {object} schemas.AuthorizationResponse
+// @Failure 400 {object} schemas.OAuthErrorResponse
+// @Failure 401 {object} schemas.OAuthErrorResponse
+// @Failure 403 {object} schemas.OAuthErrorResponse
+// @Failure 429 {object} schemas.OAuthErrorResponse
+// @Failure 500 {object} schemas.OAuthErrorResponse
+// @Router /v1/oauth/authorize [post]
+func AuthorizeHandler(oauthService *services.OAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req schemas.AuthorizeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			errors.HandleOAuthErrors(c, fmt.Errorf("%w: %s", errors.ErrInvalidRequest, err))
+			return
+		}
+
+		redirectTo, err := oauthService.Authorize(req)
+		if err != nil {
+			errors.HandleOAuthErrors(c, err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, schemas.AuthorizationResponse{RedirectTo: redirectTo})
+	}
+}
+
+// TokenHandler godoc
+// @Summary Token endpoint
+// @Description Issue tokens following RFC 6749. Supports the client_credentials, authorization_code (with PKCE) and refresh_token grants. Confidential clients authenticate with HTTP Basic or with client_id and client_secret form fields; public clients send only client_id.
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "Grant type" Enums(client_credentials, authorization_code, refresh_token)
+// @Param scope formData string false "Space-separated scopes for client_credentials, defaults to every scope of the client"
+// @Param code formData string false "Authorization code"
+// @Param redirect_uri formData string false "Redirect URI the code was issued for"
+// @Param code_verifier formData string false "PKCE code verifier"
+// @Param refresh_token formData string false "Refresh token"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Success 200 {object} schemas.TokenResponse
+// @Failure 400 {object} schemas.OAuthErrorResponse
+// @Failure 401 {object} schemas.OAuthErrorResponse
+// @Failure 500 {object} schemas.OAuthErrorResponse
+// @Router /v1/oauth/token [post]
+func TokenHandler(oauthService *services.OAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		var req schemas.TokenRequest
+		if err := c.ShouldBind(&req); err != nil {
+			errors.HandleOAuthErrors(c, fmt.Errorf("%w: %s", errors.ErrInvalidRequest, err))
+			return
+		}
+		credentials, err := clientCredentials(c)
+		if err != nil {
+			errors.HandleOAuthErrors(c, err)
+			return
+		}
+
+		response, err := oauthService.Token(credentials, req, clientInfo(c, ""))
+		if err != nil {
+			errors.HandleOAuthErrors(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// UserInfoHandler godoc
+// @Summary OpenID Connect user info
+// @Description Claims about the user the access token was issued to, limited to the scopes granted to the client
+// @Tags OAuth
+// @Produce json
+// @Success 200 {object} schemas.UserInfoResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/oauth/userinfo [get]
+// @Router /v1/oauth/userinfo [post]
+// @Security Bearer
+func UserInfoHandler(authServiceConstructor func(db *gorm.DB) *services.AuthService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		authService := authServiceConstructor(tx)
+		info, err := authService.UserInfo(userID, middlewares.CurrentSessionID(c))
+		if err != nil {
+			errors.HandleAuthErrors(c, err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+// clientCredentials reads the client authentication of a token request, sent either with
+// HTTP Basic or in the form body, but not both.
+func clientCredentials(c *gin.Context) (services.ClientCredentials, error) {
+	formClientID, formSecret := c.PostForm("client_id"), c.PostForm("client_secret")
+	username, password, ok := c.Request.BasicAuth()
+	if !ok {
+		return services.ClientCredentials{ClientID: formClientID, ClientSecret: formSecret}, nil
+	}
+	if formSecret != "" {
+		return services.ClientCredentials{}, fmt.Errorf("%w: more than one client authentication method", errors.ErrInvalidRequest)
+	}
+
+	// Basic credentials are form-encoded before being base64-encoded (RFC 6749, section 2.3.1).
+	clientID, err := url.QueryUnescape(username)
+	if err != nil {
+		return services.ClientCredentials{}, errors.ErrInvalidClient
+	}
+	secret, err := url.QueryUnescape(password)
+	if err != nil {
+		return services.ClientCredentials{}, errors.ErrInvalidClient
+	}
+	return services.ClientCredentials{ClientID: clientID, ClientSecret: secret}, nil
+}
+
+func AddOAuthRoutes(router *gin.RouterGroup, db *gorm.DB) *gin.RouterGroup {
+	oauthServiceConstructor := func(db *gorm.DB) *services.OAuthService {
+		return services.NewOAuthService(db)
+	}
+
+	// Token issuance runs outside a request transaction, so that replay detection is not
+	// rolled back together with the rejected request.
+	oauthService := services.NewOAuthService(db)
+	router.GET("/oauth/authorize", AuthorizationInfoHandler(oauthService))
+	router.POST("/oauth/authorize", AuthorizeHandler(oauthService))
+	router.POST("/oauth/token", TokenHandler(oauthService))
+
+	authServiceConstructor := func(db *gorm.DB) *services.AuthService {
+		return services.NewAuthService(db)
+	}
+	authMiddleware := middlewares.JWTAuthMiddleware(services.NewAuthService(db))
+	router.GET("/oauth/userinfo",
+		authMiddleware,
+		internal.TransactionalHandler(db, UserInfoHandler(authServiceConstructor)),
+	)
+	router.POST("/oauth/userinfo",
+		authMiddleware,
+		internal.TransactionalHandler(db, UserInfoHandler(authServiceConstructor)),
+	)
+
+	router.POST("/oauth/introspect",
+		authMiddleware,
+		middlewares.RequirePermission("tokens:introspect"),
+		internal.TransactionalHandler(db, IntrospectTokenHandler(oauthServiceConstructor)),
+	)
+
+	return router
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/services"
+	"fleet-pulse-users-service/internal/testdb"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// testKeyEncryptionKey encrypts signing keys in tests, "0123456789abcdef" twice.
+const testKeyEncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+// newTestRouter serves the v1 routes on db, like main does.
+func newTestRouter(t *testing.T, db *gorm.DB) *gin.Engine {
+	t.Helper()
+	config.Get().Auth.JwtKeyEncryptionKey = testKeyEncryptionKey
+	if _, err := services.InitSigningKeys(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.InitTokenRevocations(db); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1Group := router.Group("/v1")
+	AddUserRoutes(v1Group, db)
+	AddAuthRoutes(v1Group, db)
+	AddSessionRoutes(v1Group, db)
+	AddOAuthRoutes(v1Group, db)
+	return router
+}
+
+// createClient registers a client allowed the given scopes and returns its ID and secret.
+func createClient(t *testing.T, db *gorm.DB, scopes ...string) (string, string) {
+	t.Helper()
+	for _, scope := range []string{"trips:read", "trips:write", "users:write"} {
+		if err := db.Create(&models.Permission{Name: scope, Description: scope}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	client, secret, err := services.NewClientService(db).CreateClient(services.ClientRegistration{
+		Name:   "Telemetry ingestion",
+		Scopes: scopes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.ClientID, secret
+}
+
+func requestToken(router *gin.Engine, clientID, secret string, form url.Values) *httptest.ResponseRecorder {
+	form.Set("grant_type", services.GrantTypeClientCredentials)
+	req := httptest.NewRequest(http.MethodPost, "/v1/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, secret)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func clientToken(t *testing.T, router *gin.Engine, clientID, secret string) schemas.TokenResponse {
+	t.Helper()
+	recorder := requestToken(router, clientID, secret, url.Values{})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got %d %s, want a token", recorder.Code, recorder.Body)
+	}
+	var response schemas.TokenResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestClientCredentialsRejectsWrongSecret(t *testing.T) {
+	db := testdb.Open(t)
+	router := newTestRouter(t, db)
+	clientID, secret := createClient(t, db, "trips:read")
+
+	for name, credentials := range map[string][2]string{
+		"wrong secret":   {clientID, secret + "x"},
+		"unknown client": {"unknown", secret},
+		"no secret":      {clientID, ""},
+	} {
+		recorder := requestToken(router, credentials[0], credentials[1], url.Values{})
+		if recorder.Code != http.StatusUnauthorized || !strings.Contains(recorder.Body.String(), "invalid_client") {
+			t.Errorf("%s: got %d %s, want 401 invalid_client", name, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func TestClientCredentialsGrantsOnlyClientScopes(t *testing.T) {
+	db := testdb.Open(t)
+	router := newTestRouter(t, db)
+	clientID, secret := createClient(t, db, "trips:read", "trips:write")
+
+	response := clientToken(t, router, clientID, secret)
+	claims, err := services.NewAuthService(db).ValidateAccessToken(response.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Scope != "trips:read trips:write" || strings.Join(claims.Permissions, " ") != response.Scope {
+		t.Fatalf("got scope %q and permissions %v, want the client's scopes", response.Scope, claims.Permissions)
+	}
+	if claims.ClientID != clientID || claims.UserID != "" {
+		t.Fatalf("got a token of client %q and user %q, want the client only", claims.ClientID, claims.UserID)
+	}
+
+	recorder := requestToken(router, clientID, secret, url.Values{"scope": {"trips:read users:write"}})
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "invalid_scope") {
+		t.Fatalf("scope beyond the client's: got %d %s, want 400 invalid_scope", recorder.Code, recorder.Body)
+	}
+}
+
+func TestClientCredentialsTokenRejectedOnUserRoutes(t *testing.T) {
+	db := testdb.Open(t)
+	router := newTestRouter(t, db)
+	clientID, secret := createClient(t, db, "users:write")
+	response := clientToken(t, router, clientID, secret)
+
+	for _, route := range [][2]string{
+		{http.MethodGet, "/v1/users/current"},
+		{http.MethodGet, "/v1/sessions"},
+		{http.MethodPost, "/v1/logout/all"},
+	} {
+		req := httptest.NewRequest(route[0], route[1], nil)
+		req.Header.Set("Authorization", "Bearer "+response.AccessToken)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: got %d, want 401", route[0], route[1], recorder.Code)
+		}
+	}
+}
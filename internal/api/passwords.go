@@ -0,0 +1,125 @@
+package api
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/middlewares"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ForgotPasswordHandler godoc
+// @Summary Forgot password
+// @Description Email a single use password reset link. The response is the same whether or not the email has an account.
+// @Tags Passwords
+// @Accept json
+// @Param request body schemas.ForgotPasswordRequest true "Account email"
+// @Success 202
+// @Failure 400 {object} schemas.ErrorResponse
+// @Router /v1/password/forgot [post]
+func ForgotPasswordHandler(passwordServiceConstructor func(db *gorm.DB) *services.PasswordService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		var req schemas.ForgotPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		passwordService := passwordServiceConstructor(tx)
+		if err := passwordService.ForgotPassword(req.Email); err != nil {
+			// Failures are not surfaced either, so they cannot reveal that the account exists.
+			log.Printf("Failed to start password reset: %v", err)
+			c.Error(err)
+		}
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// ResetPasswordHandler godoc
+// @Summary Reset password
+// @Description Set a new password with a reset token and sign out of every device
+// @Tags Passwords
+// @Accept json
+// @Param request body schemas.ResetPasswordRequest true "Reset token and new password"
+// @Success 204
+// @Failure 400 {object} schemas.PasswordPolicyErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/password/reset [post]
+func ResetPasswordHandler(passwordServiceConstructor func(db *gorm.DB) *services.PasswordService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		var req schemas.ResetPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		passwordService := passwordServiceConstructor(tx)
+		if err := passwordService.ResetPassword(req.Token, req.Password); err != nil {
+			errors.HandlePasswordErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ChangePasswordHandler godoc
+// @Summary Change password
+// @Description Change the current user's password and sign out every other device. The current session stays signed in, but its access token has to be refreshed with the session's refresh token.
+// @Tags Passwords
+// @Accept json
+// @Param request body schemas.ChangePasswordRequest true "Current and new password"
+// @Success 204
+// @Failure 400 {object} schemas.PasswordPolicyErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/users/current/password [post]
+// @Security Bearer
+func ChangePasswordHandler(passwordServiceConstructor func(db *gorm.DB) *services.PasswordService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		var req schemas.ChangePasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		passwordService := passwordServiceConstructor(tx)
+		err := passwordService.ChangePassword(userID, middlewares.CurrentClaims(c), req.CurrentPassword, req.NewPassword)
+		if err != nil {
+			errors.HandlePasswordErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func AddPasswordRoutes(router *gin.RouterGroup, db *gorm.DB) *gin.RouterGroup {
+	passwordServiceConstructor := func(db *gorm.DB) *services.PasswordService {
+		return services.NewPasswordService(db)
+	}
+
+	router.POST("/password/forgot",
+		internal.TransactionalHandler(db, ForgotPasswordHandler(passwordServiceConstructor)),
+	)
+	router.POST("/password/reset",
+		internal.TransactionalHandler(db, ResetPasswordHandler(passwordServiceConstructor)),
+	)
+	router.POST("/users/current/password",
+		middlewares.JWTAuthMiddleware(services.NewAuthService(db)),
+		internal.TransactionalHandler(db, ChangePasswordHandler(passwordServiceConstructor)),
+	)
+
+	return router
+}
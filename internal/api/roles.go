@@ -0,0 +1,149 @@
+package api
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/middlewares"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListRolesHandler godoc
+// @Summary List roles
+// @Description List roles together with the permissions they grant
+// @Tags Roles
+// @Produce json
+// @Success 200 {array} schemas.RoleResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/roles [get]
+// @Security Bearer
+func ListRolesHandler(roleServiceConstructor func(db *gorm.DB) *services.RoleService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		roleService := roleServiceConstructor(tx)
+		roles, err := roleService.ListRoles()
+		if err != nil {
+			errors.HandleRoleErrors(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, toRoleResponses(roles))
+	}
+}
+
+// GetUserRolesHandler godoc
+// @Summary Get user roles
+// @Description Get roles assigned to a user of the current company
+// @Tags Roles
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {array} schemas.RoleResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Router /v1/users/{id}/roles [get]
+// @Security Bearer
+func GetUserRolesHandler(roleServiceConstructor func(db *gorm.DB) *services.RoleService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		roleService := roleServiceConstructor(tx)
+		roles, err := roleService.GetUserRoles(userID)
+		if err != nil {
+			errors.HandleRoleErrors(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, toRoleResponses(roles))
+	}
+}
+
+// SetUserRolesHandler godoc
+// @Summary Set user roles
+// @Description Replace roles assigned to a user of the current company
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param roles body schemas.SetUserRolesRequest true "Role names"
+// @Success 200 {array} schemas.RoleResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/users/{id}/roles [put]
+// @Security Bearer
+func SetUserRolesHandler(roleServiceConstructor func(db *gorm.DB) *services.RoleService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		var req schemas.SetUserRolesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		roleService := roleServiceConstructor(tx)
+		roles, err := roleService.SetUserRoles(userID, req.Roles)
+		if err != nil {
+			errors.HandleRoleErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, toRoleResponses(roles))
+	}
+}
+
+func toRoleResponses(roles []models.Role) []schemas.RoleResponse {
+	response := make([]schemas.RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		permissions := make([]string, 0, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			permissions = append(permissions, permission.Name)
+		}
+		response = append(response, schemas.RoleResponse{
+			ID:          role.ID,
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: permissions,
+		})
+	}
+	return response
+}
+
+func AddRoleRoutes(router *gin.RouterGroup, db *gorm.DB) *gin.RouterGroup {
+	roleServiceConstructor := func(db *gorm.DB) *services.RoleService {
+		return services.NewRoleService(db)
+	}
+	authMiddleware := middlewares.JWTAuthMiddleware(services.NewAuthService(db))
+
+	router.GET("/roles",
+		authMiddleware,
+		middlewares.RequirePermission("roles:read"),
+		internal.TransactionalHandler(db, ListRolesHandler(roleServiceConstructor)),
+	)
+	router.GET("/users/:id/roles",
+		authMiddleware,
+		middlewares.RequirePermission("roles:read"),
+		internal.TransactionalHandler(db, GetUserRolesHandler(roleServiceConstructor)),
+	)
+	router.PUT("/users/:id/roles",
+		authMiddleware,
+		middlewares.RequirePermission("roles:write"),
+		internal.TransactionalHandler(db, SetUserRolesHandler(roleServiceConstructor)),
+	)
+
+	return router
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/middlewares"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListSessionsHandler godoc
+// @Summary List sessions
+// @Description List devices the current user is signed in on
+// @Tags Sessions
+// @Produce json
+// @Success 200 {array} schemas.SessionResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/sessions [get]
+// @Security Bearer
+func ListSessionsHandler(sessionServiceConstructor func(db *gorm.DB) *services.SessionService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		sessionService := sessionServiceConstructor(tx)
+		sessions, err := sessionService.ListSessions(userID)
+		if err != nil {
+			errors.HandleSessionErrors(c, err)
+			return
+		}
+
+		currentSessionID := middlewares.CurrentSessionID(c)
+		response := make([]schemas.SessionResponse, 0, len(sessions))
+		for _, session := range sessions {
+			response = append(response, schemas.SessionResponse{
+				ID:         session.ID,
+				DeviceName: session.DeviceName,
+				UserAgent:  session.UserAgent,
+				IPAddress:  session.IPAddress,
+				ClientID:   session.ClientID,
+				CreatedAt:  session.CreatedAt,
+				LastUsedAt: session.LastUsedAt,
+				ExpiresAt:  session.ExpiresAt,
+				Current:    currentSessionID != nil && *currentSessionID == session.ID,
+			})
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// RevokeSessionHandler godoc
+// @Summary Revoke session
+// @Description Sign the current user out of one of their devices
+// @Tags Sessions
+// @Param id path string true "Session ID"
+// @Success 204
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/sessions/{id} [delete]
+// @Security Bearer
+func RevokeSessionHandler(sessionServiceConstructor func(db *gorm.DB) *services.SessionService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		sessionID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		sessionService := sessionServiceConstructor(tx)
+		if err := sessionService.RevokeSession(userID, sessionID); err != nil {
+			errors.HandleSessionErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func AddSessionRoutes(router *gin.RouterGroup, db *gorm.DB) *gin.RouterGroup {
+	sessionServiceConstructor := func(db *gorm.DB) *services.SessionService {
+		return services.NewSessionService(db)
+	}
+	authMiddleware := middlewares.JWTAuthMiddleware(services.NewAuthService(db))
+
+	router.GET("/sessions",
+		authMiddleware,
+		internal.TransactionalHandler(db, ListSessionsHandler(sessionServiceConstructor)),
+	)
+	router.DELETE("/sessions/:id",
+		authMiddleware,
+		internal.TransactionalHandler(db, RevokeSessionHandler(sessionServiceConstructor)),
+	)
+
+	return router
+}
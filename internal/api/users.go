@@ -4,24 +4,25 @@ import (
 	"fleet-pulse-users-service/internal"
 	"fleet-pulse-users-service/internal/errors"
 	"fleet-pulse-users-service/internal/middlewares"
+	"fleet-pulse-users-service/internal/models"
 	"fleet-pulse-users-service/internal/schemas"
 	"fleet-pulse-users-service/internal/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // RegisterUserHandler godoc
 // @Summary Register a new user
-// @Description Create a new user account
+// @Description Sign up without a company. Fleet admins invite company users through /v1/companies/{id}/invites instead.
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param user body schemas.CreateUserRequest true "User data"
 // @Success 201 {object} schemas.UserResponse
-// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 400 {object} schemas.PasswordPolicyErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
 // @Failure 409 {object} schemas.ErrorResponse
 // @Failure 500 {object} schemas.ErrorResponse
 // @Router /v1/users [post]
@@ -41,12 +42,7 @@ func RegisterUserHandler(userServiceConstructor func(db *gorm.DB) *services.User
 			return
 		}
 
-		c.JSON(http.StatusCreated, schemas.UserResponse{
-			ID:        createdUser.ID,
-			Email:     createdUser.Email,
-			FirstName: createdUser.FirstName,
-			LastName:  createdUser.LastName,
-		})
+		c.JSON(http.StatusCreated, toUserResponse(createdUser))
 	}
 }
 
@@ -62,18 +58,12 @@ func RegisterUserHandler(userServiceConstructor func(db *gorm.DB) *services.User
 // @Security Bearer
 func GetCurrentUserHandler(userServiceConstructor func(db *gorm.DB) *services.UserService) func(c *gin.Context, tx *gorm.DB) {
 	return func(c *gin.Context, tx *gorm.DB) {
-		userID, exists := c.Get("current_user_id")
-		if !exists {
+		userUUID, ok := middlewares.CurrentUserID(c)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 
-		userUUID, err := uuid.Parse(userID.(string))
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
-			return
-		}
-
 		userService := userServiceConstructor(tx)
 		user, err := userService.GetUserById(userUUID)
 		if err != nil || user == nil {
@@ -81,12 +71,7 @@ func GetCurrentUserHandler(userServiceConstructor func(db *gorm.DB) *services.Us
 			return
 		}
 
-		c.JSON(http.StatusOK, schemas.UserResponse{
-			ID:        user.ID,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			Email:     user.Email,
-		})
+		c.JSON(http.StatusOK, toUserResponse(user))
 	}
 }
 
@@ -98,8 +83,10 @@ func GetCurrentUserHandler(userServiceConstructor func(db *gorm.DB) *services.Us
 // @Produce json
 // @Param accept body schemas.AcceptInviteRequest true "Accept invite request"
 // @Success 200 {object} schemas.UserResponse
-// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 400 {object} schemas.PasswordPolicyErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
 // @Failure 404 {object} schemas.ErrorResponse
+// @Failure 409 {object} schemas.ErrorResponse
 // @Failure 500 {object} schemas.ErrorResponse
 // @Router /v1/users/invite/accept [post]
 func AcceptInviteHandler(userServiceConstructor func(db *gorm.DB) *services.UserService) func(c *gin.Context, tx *gorm.DB) {
@@ -113,16 +100,53 @@ func AcceptInviteHandler(userServiceConstructor func(db *gorm.DB) *services.User
 		userService := userServiceConstructor(tx)
 		user, err := userService.AcceptInvite(req.Token, req.Password)
 		if err != nil {
+			errors.HandleInviteErrors(c, err)
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, toUserResponse(user))
+	}
+}
+
+// RevokeUserTokensHandler godoc
+// @Summary Revoke user tokens
+// @Description End every session of a user of the current company and reject their access tokens immediately
+// @Tags Users
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/users/{id}/revoke-tokens [post]
+// @Security Bearer
+func RevokeUserTokensHandler(userServiceConstructor func(db *gorm.DB) *services.UserService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		userService := userServiceConstructor(tx)
+		if err := userService.RevokeUserTokens(userID); err != nil {
 			errors.HandleUserErrors(c, err)
+			c.Error(err)
 			return
 		}
+		c.Status(http.StatusNoContent)
+	}
+}
 
-		c.JSON(http.StatusOK, schemas.UserResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-		})
+func toUserResponse(user *models.User) schemas.UserResponse {
+	return schemas.UserResponse{
+		ID:         user.ID,
+		Email:      user.Email,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		CompanyID:  user.CompanyID,
+		AcceptedAt: user.AcceptedAt,
 	}
 }
 
@@ -144,5 +168,11 @@ func AddUserRoutes(router *gin.RouterGroup, db *gorm.DB) *gin.RouterGroup {
 		internal.TransactionalHandler(db, AcceptInviteHandler(userServiceConstructor)),
 	)
 
+	router.POST("/users/:id/revoke-tokens",
+		middlewares.JWTAuthMiddleware(services.NewAuthService(db)),
+		middlewares.RequirePermission("users:write"),
+		internal.TransactionalHandler(db, RevokeUserTokensHandler(userServiceConstructor)),
+	)
+
 	return router
 }
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/middlewares"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListPasskeysHandler godoc
+// @Summary List passkeys
+// @Description List the passkeys the current user can sign in with
+// @Tags Passkeys
+// @Produce json
+// @Success 200 {array} schemas.PasskeyResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/users/current/passkeys [get]
+// @Security Bearer
+func ListPasskeysHandler(webAuthnServiceConstructor func(db *gorm.DB) *services.WebAuthnService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		webAuthnService := webAuthnServiceConstructor(tx)
+		credentials, err := webAuthnService.ListCredentials(userID)
+		if err != nil {
+			errors.HandleWebAuthnErrors(c, err)
+			return
+		}
+		response := make([]schemas.PasskeyResponse, 0, len(credentials))
+		for i := range credentials {
+			response = append(response, toPasskeyResponse(&credentials[i]))
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// BeginPasskeyRegistrationHandler godoc
+// @Summary Begin passkey registration
+// @Description Get the options for navigator.credentials.create to add a passkey for the current user.
+// @Description The current password, and a code for users with two-factor authentication, are checked first.
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Param registration body schemas.PasskeyRegistrationBeginRequest true "Current password and two-factor code"
+// @Success 200 {object} schemas.PasskeyCeremonyResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 429 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/users/current/passkeys/registration/begin [post]
+// @Security Bearer
+func BeginPasskeyRegistrationHandler(webAuthnServiceConstructor func(db *gorm.DB) *services.WebAuthnService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req schemas.PasskeyRegistrationBeginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		webAuthnService := webAuthnServiceConstructor(tx)
+		ceremony, err := webAuthnService.BeginRegistration(userID, req.CurrentPassword, req.MFACode)
+		if err != nil {
+			errors.HandleWebAuthnErrors(c, err)
+			// A wrong code is counted towards the lockout, which must not be rolled back.
+			if !errors.IsCountedMFAFailure(err) {
+				c.Error(err)
+			}
+			return
+		}
+		c.JSON(http.StatusOK, toPasskeyCeremonyResponse(ceremony))
+	}
+}
+
+// FinishPasskeyRegistrationHandler godoc
+// @Summary Finish passkey registration
+// @Description Store the passkey created by navigator.credentials.create
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Param registration body schemas.PasskeyRegistrationFinishRequest true "Session and credential"
+// @Success 201 {object} schemas.PasskeyResponse
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 409 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/users/current/passkeys/registration/finish [post]
+// @Security Bearer
+func FinishPasskeyRegistrationHandler(webAuthnServiceConstructor func(db *gorm.DB) *services.WebAuthnService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		var req schemas.PasskeyRegistrationFinishRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		webAuthnService := webAuthnServiceConstructor(tx)
+		credential, err := webAuthnService.FinishRegistration(userID, req.SessionID, req.Name, req.Credential)
+		if err != nil {
+			errors.HandleWebAuthnErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusCreated, toPasskeyResponse(credential))
+	}
+}
+
+// DeletePasskeyHandler godoc
+// @Summary Delete passkey
+// @Description Remove a passkey of the current user, it can no longer be used to sign in
+// @Tags Passkeys
+// @Param id path string true "Passkey ID"
+// @Success 204
+// @Failure 400 {object} schemas.ErrorResponse
+// @Failure 401 {object} schemas.ErrorResponse
+// @Failure 403 {object} schemas.ErrorResponse
+// @Failure 404 {object} schemas.ErrorResponse
+// @Failure 500 {object} schemas.ErrorResponse
+// @Router /v1/users/current/passkeys/{id} [delete]
+// @Security Bearer
+func DeletePasskeyHandler(webAuthnServiceConstructor func(db *gorm.DB) *services.WebAuthnService) func(c *gin.Context, tx *gorm.DB) {
+	return func(c *gin.Context, tx *gorm.DB) {
+		userID, ok := middlewares.CurrentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := parseUUIDParam(c, "id")
+		if !ok {
+			return
+		}
+
+		webAuthnService := webAuthnServiceConstructor(tx)
+		if err := webAuthnService.DeleteCredential(userID, id); err != nil {
+			errors.HandleWebAuthnErrors(c, err)
+			c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func toPasskeyResponse(credential *models.WebAuthnCredential) schemas.PasskeyResponse {
+	return schemas.PasskeyResponse{
+		ID:             credential.ID,
+		Name:           credential.Name,
+		Transports:     strings.Fields(credential.Transports),
+		BackupEligible: credential.BackupEligible,
+		BackupState:    credential.BackupState,
+		CloneWarning:   credential.CloneWarning,
+		CreatedAt:      credential.CreatedAt,
+		LastUsedAt:     credential.LastUsedAt,
+	}
+}
+
+func toPasskeyCeremonyResponse(ceremony *services.PasskeyCeremony) schemas.PasskeyCeremonyResponse {
+	return schemas.PasskeyCeremonyResponse{
+		SessionID: ceremony.SessionID,
+		Options:   ceremony.Options,
+		ExpiresAt: ceremony.ExpiresAt,
+	}
+}
+
+func AddPasskeyRoutes(router *gin.RouterGroup, db *gorm.DB) *gin.RouterGroup {
+	webAuthnServiceConstructor := func(db *gorm.DB) *services.WebAuthnService {
+		return services.NewWebAuthnService(db)
+	}
+
+	passkeys := router.Group("/users/current/passkeys",
+		middlewares.JWTAuthMiddleware(services.NewAuthService(db)),
+		middlewares.RequireDirectSignIn(),
+	)
+
+	passkeys.GET("",
+		internal.TransactionalHandler(db, ListPasskeysHandler(webAuthnServiceConstructor)),
+	)
+	passkeys.POST("/registration/begin",
+		internal.TransactionalHandler(db, BeginPasskeyRegistrationHandler(webAuthnServiceConstructor)),
+	)
+	passkeys.POST("/registration/finish",
+		internal.TransactionalHandler(db, FinishPasskeyRegistrationHandler(webAuthnServiceConstructor)),
+	)
+	passkeys.DELETE("/:id",
+		internal.TransactionalHandler(db, DeletePasskeyHandler(webAuthnServiceConstructor)),
+	)
+
+	return router
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler godoc
+// @Summary JSON Web Key Set
+// @Description Public keys access tokens are signed with. Tokens carry the kid of their key.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} schemas.JWKSResponse
+// @Router /.well-known/jwks.json [get]
+func JWKSHandler(keyRing *services.KeyRing) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, keyRing.JWKS())
+	}
+}
+
+// OpenIDConfigurationHandler godoc
+// @Summary OpenID Connect discovery
+// @Description Describes this service as an OpenID Connect provider. Unavailable until OAUTH_AUTHORIZATION_ENDPOINT
+// @Description points at the sign-in page.
+// @Tags OAuth
+// @Produce json
+// @Success 200 {object} schemas.OpenIDConfiguration
+// @Failure 503 {object} schemas.OAuthErrorResponse
+// @Router /.well-known/openid-configuration [get]
+func OpenIDConfigurationHandler() gin.HandlerFunc {
+	configuration, err := services.OpenIDConfiguration()
+	if err != nil {
+		log.Printf("OpenID Connect discovery is unavailable: %v", err)
+	}
+	return func(c *gin.Context) {
+		if err != nil {
+			errors.HandleOAuthErrors(c, err)
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=3600")
+		c.JSON(http.StatusOK, configuration)
+	}
+}
+
+func AddWellKnownRoutes(router *gin.Engine) {
+	router.GET("/.well-known/jwks.json", JWKSHandler(services.SigningKeys()))
+	router.GET("/.well-known/openid-configuration", OpenIDConfigurationHandler())
+}
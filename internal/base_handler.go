@@ -5,9 +5,11 @@ import (
 	"gorm.io/gorm"
 )
 
+// TransactionalHandler runs the handler inside a transaction bound to the request context,
+// so repositories built on the transaction pick up the caller's tenant.
 func TransactionalHandler(db *gorm.DB, handler func(c *gin.Context, tx *gorm.DB)) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		_ = db.Transaction(func(tx *gorm.DB) error {
+		_ = db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
 			handler(c, tx)
 			if len(c.Errors) > 0 {
 				return c.Errors.Last().Err
@@ -20,9 +20,15 @@ func NewBaseRepository[T any, ID comparable](db *gorm.DB) *BaseRepository[T, ID]
 	return &BaseRepository[T, ID]{db: db}
 }
 
+// Scoped returns a query limited to the tenant carried by the database context.
+// Repositories must build their queries on top of it instead of the raw connection.
+func (r *BaseRepository[T, ID]) Scoped() *gorm.DB {
+	return r.db.Scopes(TenantScope[T])
+}
+
 func (r *BaseRepository[T, ID]) GetById(id ID) (*T, error) {
 	var entity T
-	if err := r.db.First(&entity, "id = ?", id).Error; err != nil {
+	if err := r.Scoped().First(&entity, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &entity, nil
@@ -36,7 +42,7 @@ func (r *BaseRepository[T, ID]) Create(entity *T) (*T, error) {
 }
 
 func (r *BaseRepository[T, ID]) Update(instance *T, inputData T) (*T, error) {
-	if err := r.db.Model(instance).Updates(inputData).Error; err != nil {
+	if err := r.Scoped().Model(instance).Updates(inputData).Error; err != nil {
 		return nil, err
 	}
 	return instance, nil
@@ -44,9 +50,9 @@ func (r *BaseRepository[T, ID]) Update(instance *T, inputData T) (*T, error) {
 
 func (r *BaseRepository[T, ID]) DeleteById(id ID) error {
 	var entity T
-	return r.db.Delete(&entity, "id = ?", id).Error
+	return r.Scoped().Delete(&entity, "id = ?", id).Error
 }
 
 func (r *BaseRepository[T, ID]) DeleteObj(instance *T) error {
-	return r.db.Delete(instance).Error
+	return r.Scoped().Delete(instance).Error
 }
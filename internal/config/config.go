@@ -2,8 +2,10 @@ package config
 
 import (
 	"log"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/joho/godotenv"
@@ -13,10 +15,13 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	Auth     AuthConfig
+	Mail     MailConfig
 }
 
 type ServerConfig struct {
 	Port string
+	// FrontendBaseURL is where links in emails, such as invite links, point to.
+	FrontendBaseURL string
 }
 
 type DatabaseConfig struct {
@@ -28,11 +33,55 @@ type DatabaseConfig struct {
 }
 
 type AuthConfig struct {
-	JwtSecret                     string
+	JwtPrivateKeyFile string
+	// JwtKeyEncryptionKey is the base64 encoded AES-256 key signing keys are encrypted with
+	// in the database.
+	JwtKeyEncryptionKey           string
 	JwtAccessTokenExpireInMinutes int
 	JwtRefreshTokenExpireInHours  int
 	InviteSecret                  string
 	InviteExpireInMinutes         int
+	PasswordResetExpireInMinutes  int
+	// SelfSignupEnabled lets anyone create an account through POST /v1/users.
+	SelfSignupEnabled bool
+	// Password policy applied whenever a user sets a password.
+	PasswordMinLength           int
+	PasswordMaxLength           int
+	PasswordMinCharacterClasses int
+	PasswordForbidPersonalInfo  bool
+	PasswordCheckBreached       bool
+	// PasswordHasher hashes new passwords, argon2id or bcrypt. Hashes made by the other one
+	// still verify and are upgraded on the next login.
+	PasswordHasher    string
+	Argon2MemoryKiB   int
+	Argon2Iterations  int
+	Argon2Parallelism int
+	BcryptCost        int
+	// MFAIssuer names this service in authenticator apps.
+	MFAIssuer string
+	// WebAuthn relying party for passkeys. The RP ID is the domain passkeys are bound to and
+	// the origins are the pages allowed to run the ceremonies, e.g. the sign-in page.
+	WebAuthnRPID      string
+	WebAuthnRPName    string
+	WebAuthnRPOrigins []string
+	// Issuer identifies this service in the iss claim and OpenID Connect discovery.
+	Issuer string
+	// AuthorizationEndpoint is the sign-in page that drives the authorize API. Clients redirect
+	// browsers there, so it cannot be the JSON API itself; discovery fails until it is set.
+	AuthorizationEndpoint string
+}
+
+type MailConfig struct {
+	// Driver selects how emails are delivered: smtp, log, file or memory. It defaults to smtp,
+	// the other drivers keep reset links and invites readable and are meant for development.
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// FileDir is where the file driver writes one .eml file per email.
+	FileDir string
 }
 
 var (
@@ -51,19 +100,18 @@ func load() *Config {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
-	jwtAccessTokenExpire, err := strconv.Atoi(getEnv("JWT_ACCESS_TOKEN_EXPIRE_IN_MINUTES", "5432"))
-	if err != nil {
-		log.Fatal(err)
-	}
+	issuer := strings.TrimSuffix(getEnv("ISSUER_URL", "http://localhost:8000"), "/")
+	frontendBaseURL := strings.TrimSuffix(getEnv("FRONTEND_BASE_URL", "http://localhost:3000"), "/")
 
-	jwtRefreshTokenExpire, err := strconv.Atoi(getEnv("JWT_REFRESH_TOKEN_EXPIRE_IN_HOURS", "5432"))
+	frontendURL, err := url.Parse(frontendBaseURL)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", ":8000"),
+			Port:            getEnv("PORT", ":8000"),
+			FrontendBaseURL: frontendBaseURL,
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -73,11 +121,39 @@ func load() *Config {
 			Name:     getEnv("DB_NAME", ""),
 		},
 		Auth: AuthConfig{
-			JwtSecret:                     getEnv("JWT_SECRET", ""),
-			JwtAccessTokenExpireInMinutes: jwtAccessTokenExpire,
-			JwtRefreshTokenExpireInHours:  jwtRefreshTokenExpire,
+			JwtPrivateKeyFile:             getEnv("JWT_PRIVATE_KEY_FILE", ""),
+			JwtKeyEncryptionKey:           getEnv("JWT_KEY_ENCRYPTION_KEY", ""),
+			JwtAccessTokenExpireInMinutes: getEnvInt("JWT_ACCESS_TOKEN_EXPIRE_IN_MINUTES", 5432),
+			JwtRefreshTokenExpireInHours:  getEnvInt("JWT_REFRESH_TOKEN_EXPIRE_IN_HOURS", 5432),
 			InviteSecret:                  getEnv("INVITE_SECRET", ""),
-			InviteExpireInMinutes:         60,
+			InviteExpireInMinutes:         getEnvInt("INVITE_EXPIRE_IN_MINUTES", 4320),
+			PasswordResetExpireInMinutes:  getEnvInt("PASSWORD_RESET_EXPIRE_IN_MINUTES", 30),
+			SelfSignupEnabled:             getEnvBool("SELF_SIGNUP_ENABLED", true),
+			PasswordMinLength:             getEnvInt("PASSWORD_MIN_LENGTH", 10),
+			PasswordMaxLength:             getEnvInt("PASSWORD_MAX_LENGTH", 72),
+			PasswordMinCharacterClasses:   getEnvInt("PASSWORD_MIN_CHARACTER_CLASSES", 3),
+			PasswordForbidPersonalInfo:    getEnvBool("PASSWORD_FORBID_PERSONAL_INFO", true),
+			PasswordCheckBreached:         getEnvBool("PASSWORD_CHECK_BREACHED", true),
+			PasswordHasher:                getEnv("PASSWORD_HASHER", "argon2id"),
+			Argon2MemoryKiB:               getEnvInt("ARGON2_MEMORY_KIB", 64*1024),
+			Argon2Iterations:              getEnvInt("ARGON2_ITERATIONS", 3),
+			Argon2Parallelism:             getEnvInt("ARGON2_PARALLELISM", 2),
+			BcryptCost:                    getEnvInt("BCRYPT_COST", 10),
+			MFAIssuer:                     getEnv("MFA_ISSUER", "Fleet Pulse"),
+			WebAuthnRPID:                  getEnv("WEBAUTHN_RP_ID", frontendURL.Hostname()),
+			WebAuthnRPName:                getEnv("WEBAUTHN_RP_NAME", "Fleet Pulse"),
+			WebAuthnRPOrigins:             strings.Split(getEnv("WEBAUTHN_RP_ORIGINS", frontendBaseURL), ","),
+			Issuer:                        issuer,
+			AuthorizationEndpoint:         getEnv("OAUTH_AUTHORIZATION_ENDPOINT", ""),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "smtp"),
+			From:         getEnv("MAIL_FROM", "Fleet Pulse <no-reply@fleetpulse.local>"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			FileDir:      getEnv("MAIL_FILE_DIR", "mail"),
 		},
 	}
 }
@@ -88,3 +164,19 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, strconv.Itoa(defaultValue)))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, strconv.FormatBool(defaultValue)))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return value
+}
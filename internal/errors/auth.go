@@ -9,6 +9,9 @@ import (
 
 var ErrInvalidToken = errors.New("invalid token")
 var ErrExpiredToken = errors.New("user with such email already exists")
+var ErrPermissionDenied = errors.New("permission denied")
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+var ErrDirectSignInRequired = errors.New("this requires signing in to this service directly, not through an OAuth client")
 
 func HandleAuthErrors(ctx *gin.Context, err error) {
 	switch {
@@ -18,6 +21,10 @@ func HandleAuthErrors(ctx *gin.Context, err error) {
 		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 	case errors.Is(err, ErrInvalidCredentials):
 		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrRefreshTokenReused):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrPermissionDenied), errors.Is(err, ErrDirectSignInRequired):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 	default:
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
 	}
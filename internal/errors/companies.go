@@ -0,0 +1,27 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrCompanyNotFound = errors.New("company not found")
+var ErrAlreadyCompanyMember = errors.New("user already belongs to a company")
+var ErrNotCompanyMember = errors.New("user is not a member of this company")
+
+func HandleCompanyErrors(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrCompanyNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrAlreadyCompanyMember):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrNotCompanyMember):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+	}
+}
@@ -0,0 +1,34 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInviteNotFound = errors.New("invite not found")
+var ErrInviteRevoked = errors.New("invite has been revoked")
+var ErrInviteExpired = errors.New("invite has expired")
+var ErrInviteAlreadyAccepted = errors.New("invite has already been accepted")
+var ErrInvalidInviteStatus = errors.New("invalid invite status")
+
+func HandleInviteErrors(ctx *gin.Context, err error) {
+	if handlePasswordPolicyError(ctx, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, ErrInviteNotFound), errors.Is(err, ErrUserNotFound), errors.Is(err, ErrCompanyNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidInviteToken), errors.Is(err, ErrInviteRevoked), errors.Is(err, ErrInviteExpired):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrRoleNotFound):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInviteAlreadyAccepted), errors.Is(err, ErrEmailAlreadyExists):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidInviteStatus):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+	}
+}
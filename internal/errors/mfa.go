@@ -0,0 +1,39 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrMFARequired = errors.New("a two-factor authentication code is required")
+var ErrInvalidMFACode = errors.New("invalid two-factor authentication code")
+var ErrInvalidMFAChallenge = errors.New("invalid or expired two-factor authentication challenge, sign in again")
+var ErrMFALocked = errors.New("too many wrong two-factor authentication codes, try again later")
+var ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+var ErrMFANotEnabled = errors.New("two-factor authentication is not enabled")
+var ErrMFAEnrollmentNotStarted = errors.New("two-factor authentication enrollment has not been started")
+
+// IsCountedMFAFailure reports whether a wrong code was counted towards the user's lockout.
+// Transactional handlers commit after such errors, or the count would be rolled back.
+func IsCountedMFAFailure(err error) bool {
+	return errors.Is(err, ErrInvalidMFACode) || errors.Is(err, ErrMFALocked)
+}
+
+func HandleMFAErrors(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidMFACode), errors.Is(err, ErrInvalidMFAChallenge):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrMFARequired), errors.Is(err, ErrMFANotEnabled), errors.Is(err, ErrMFAEnrollmentNotStarted):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrMFALocked):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrMFAAlreadyEnabled):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+	}
+}
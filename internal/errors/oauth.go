@@ -0,0 +1,58 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidRequest = errors.New("the request is missing a parameter or is malformed")
+var ErrInvalidClient = errors.New("client authentication failed")
+var ErrUnsupportedGrantType = errors.New("the grant type is not supported")
+var ErrInvalidScope = errors.New("the requested scope is invalid or exceeds the scope granted to the client")
+var ErrInvalidGrant = errors.New("the authorization grant or refresh token is invalid, expired or revoked")
+var ErrUnauthorizedClient = errors.New("the client is not allowed to use this grant type")
+var ErrUnsupportedResponseType = errors.New("the response type is not supported")
+var ErrAccessDenied = errors.New("the user denied the request or may not use the client")
+var ErrConsentRequired = errors.New("the user has to approve the requested scopes")
+var ErrAuthorizationEndpointNotConfigured = errors.New("OAUTH_AUTHORIZATION_ENDPOINT must be set to the sign-in page")
+
+// HandleOAuthErrors writes errors of the OAuth endpoints in the RFC 6749 format,
+// with the error code in "error" and the message in "error_description".
+func HandleOAuthErrors(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+	case errors.Is(err, ErrInvalidClient):
+		ctx.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": err.Error()})
+	case errors.Is(err, ErrUnsupportedGrantType):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type", "error_description": err.Error()})
+	case errors.Is(err, ErrInvalidScope):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "error_description": err.Error()})
+	case errors.Is(err, ErrInvalidGrant):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": err.Error()})
+	case errors.Is(err, ErrUnauthorizedClient):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized_client", "error_description": err.Error()})
+	case errors.Is(err, ErrUnsupportedResponseType):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_response_type", "error_description": err.Error()})
+	case errors.Is(err, ErrConsentRequired):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "consent_required", "error_description": err.Error()})
+	case errors.Is(err, ErrAccessDenied):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "access_denied", "error_description": err.Error()})
+	case errors.Is(err, ErrMFARequired):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "mfa_required", "error_description": err.Error()})
+	case errors.Is(err, ErrInvalidMFACode):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "access_denied", "error_description": err.Error()})
+	case errors.Is(err, ErrMFALocked):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": "access_denied", "error_description": err.Error()})
+	case errors.Is(err, ErrAuthorizationEndpointNotConfigured):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable", "error_description": err.Error()})
+	case errors.Is(err, ErrInvalidCredentials), errors.Is(err, ErrUserNotFound):
+		// Both are reported alike so that the endpoint does not reveal which emails exist.
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "access_denied", "error_description": ErrInvalidCredentials.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "Something went wrong"})
+	}
+}
@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+var ErrPasswordUnchanged = errors.New("new password must differ from the current one")
+var ErrWeakPassword = errors.New("password does not meet the password policy")
+
+// PasswordViolation is one unmet password policy rule.
+type PasswordViolation struct {
+	Code    string `json:"code" example:"too_short"`
+	Message string `json:"message" example:"must be at least 10 characters long"`
+}
+
+// PasswordPolicyError lists every rule a password breaks. It matches ErrWeakPassword.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return ErrWeakPassword.Error()
+}
+
+func (e *PasswordPolicyError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+// handlePasswordPolicyError writes the violations of a PasswordPolicyError and reports whether err was one.
+func handlePasswordPolicyError(ctx *gin.Context, err error) bool {
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": policyErr.Error(), "violations": policyErr.Violations})
+	return true
+}
+
+func HandlePasswordErrors(ctx *gin.Context, err error) {
+	if handlePasswordPolicyError(ctx, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, ErrInvalidResetToken), errors.Is(err, ErrPasswordUnchanged):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidCredentials):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+	}
+}
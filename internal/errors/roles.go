@@ -0,0 +1,21 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrRoleNotFound = errors.New("role not found")
+
+func HandleRoleErrors(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrRoleNotFound):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+	}
+}
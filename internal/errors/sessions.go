@@ -0,0 +1,19 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func HandleSessionErrors(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+	}
+}
@@ -11,8 +11,12 @@ var ErrUserNotFound = errors.New("user not found")
 var ErrEmailAlreadyExists = errors.New("user with such email already exists")
 var ErrInvalidCredentials = errors.New("invalid credentials")
 var ErrInvalidInviteToken = errors.New("invalid invitation")
+var ErrSelfSignupDisabled = errors.New("self signup is disabled, ask your fleet admin for an invite")
 
 func HandleUserErrors(ctx *gin.Context, err error) {
+	if handlePasswordPolicyError(ctx, err) {
+		return
+	}
 	switch {
 	case errors.Is(err, ErrEmailAlreadyExists):
 		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
@@ -20,7 +24,7 @@ func HandleUserErrors(ctx *gin.Context, err error) {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 	case errors.Is(err, ErrInvalidCredentials):
 		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-	case errors.Is(err, ErrInvalidInviteToken):
+	case errors.Is(err, ErrInvalidInviteToken), errors.Is(err, ErrSelfSignupDisabled):
 		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 	default:
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
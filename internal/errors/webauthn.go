@@ -0,0 +1,37 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrPasskeyNotFound = errors.New("passkey not found")
+var ErrPasskeyAlreadyRegistered = errors.New("this passkey is already registered")
+var ErrInvalidPasskeySession = errors.New("invalid or expired passkey ceremony, start again")
+var ErrInvalidPasskeyCredential = errors.New("the passkey response could not be verified")
+var ErrPasskeyCloneWarning = errors.New("the passkey looks cloned and was not accepted, sign in another way")
+
+func HandleWebAuthnErrors(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidPasskeyCredential), errors.Is(err, ErrPasskeyCloneWarning):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidPasskeySession):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrPasskeyNotFound), errors.Is(err, ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrPasskeyAlreadyRegistered):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidCredentials):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrMFARequired):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidMFACode):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrMFALocked):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+	}
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"fleet-pulse-users-service/internal/config"
+	"log"
+	"sync"
+)
+
+// Message is a single email with an HTML and a plain-text alternative.
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer delivers emails.
+type Mailer interface {
+	Send(message Message) error
+}
+
+var (
+	instance Mailer
+	once     sync.Once
+)
+
+// Get returns the process-wide mailer selected by MAIL_DRIVER.
+func Get() Mailer {
+	once.Do(func() {
+		instance = New(config.Get().Mail)
+	})
+	return instance
+}
+
+// New builds the mailer for the configured driver.
+func New(settings config.MailConfig) Mailer {
+	switch settings.Driver {
+	case "smtp":
+		return NewSMTPMailer(settings)
+	case "file":
+		return NewFileMailer(settings.FileDir, settings.From)
+	case "memory":
+		return NewMemoryMailer()
+	case "log":
+		log.Println("MAIL_DRIVER=log writes emails with their links to the log, use it for development only")
+		return NewLogMailer()
+	default:
+		log.Fatalf("Unknown MAIL_DRIVER %q", settings.Driver)
+		return nil
+	}
+}
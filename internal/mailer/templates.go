@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/*.html.tmpl"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/*.txt.tmpl"))
+)
+
+// InviteEmail holds what the invite templates show.
+type InviteEmail struct {
+	FirstName   string
+	CompanyName string
+	AcceptURL   string
+	ExpiresAt   time.Time
+}
+
+// RenderInvite builds the invite email for the recipient.
+func RenderInvite(to string, data InviteEmail) (Message, error) {
+	return render(to, "You have been invited to Fleet Pulse", "invite", data)
+}
+
+// PasswordResetEmail holds what the password reset templates show.
+type PasswordResetEmail struct {
+	FirstName string
+	ResetURL  string
+	ExpiresAt time.Time
+}
+
+// RenderPasswordReset builds the password reset email for the recipient.
+func RenderPasswordReset(to string, data PasswordResetEmail) (Message, error) {
+	return render(to, "Reset your Fleet Pulse password", "password_reset", data)
+}
+
+// render fills in the HTML and plain-text templates sharing the given name.
+func render(to, subject, name string, data interface{}) (Message, error) {
+	var html, text bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html.tmpl", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt.tmpl", data); err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: subject, HTML: html.String(), Text: text.String()}, nil
+}
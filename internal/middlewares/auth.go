@@ -1,12 +1,15 @@
 package middlewares
 
 import (
+	"fleet-pulse-users-service/internal"
 	"fleet-pulse-users-service/internal/errors"
 	"fleet-pulse-users-service/internal/services"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 func JWTAuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
@@ -26,13 +29,77 @@ func JWTAuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 		}
 
 		token := parts[1]
-		claims, err := authService.ParseJWT(token)
+		claims, err := authService.ValidateAccessToken(token)
 		if err != nil {
 			errors.HandleAuthErrors(c, err)
 			c.Abort()
 			return
 		}
 		c.Set("current_user_id", claims.UserID)
+		c.Set("current_company_id", claims.CompanyID)
+		c.Set("current_user_roles", claims.Roles)
+		c.Set("current_user_permissions", claims.Permissions)
+		c.Set("current_session_id", claims.SessionID)
+		c.Set("current_client_id", claims.ClientID)
+		c.Set("current_claims", claims)
+		// Client credentials tokens not bound to a company serve the whole platform and are not
+		// tenant-scoped. Tokens of users always are, even when issued through a client.
+		if claims.UserID != "" || claims.CompanyID != nil {
+			c.Request = c.Request.WithContext(internal.WithTenant(c.Request.Context(), claims.CompanyID))
+		}
 		c.Next()
 	}
 }
+
+// RequirePermission rejects requests whose user was not granted the permission.
+// It must run after JWTAuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(c.GetStringSlice("current_user_permissions"), permission) {
+			errors.HandleAuthErrors(c, errors.ErrPermissionDenied)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireDirectSignIn rejects access tokens issued to OAuth clients, for requests that only
+// the user may make, like managing how they sign in. It must run after JWTAuthMiddleware.
+func RequireDirectSignIn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("current_client_id") != "" {
+			errors.HandleAuthErrors(c, errors.ErrDirectSignInRequired)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentUserID returns the ID of the user authenticated by JWTAuthMiddleware.
+func CurrentUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, exists := c.Get("current_user_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return userUUID, true
+}
+
+// CurrentClaims returns the claims of the access token the request was made with.
+func CurrentClaims(c *gin.Context) *services.Claims {
+	claims, _ := c.Get("current_claims")
+	current, _ := claims.(*services.Claims)
+	return current
+}
+
+// CurrentSessionID returns the session the access token was issued for, if any.
+func CurrentSessionID(c *gin.Context) *uuid.UUID {
+	sessionID, _ := c.Get("current_session_id")
+	id, _ := sessionID.(*uuid.UUID)
+	return id
+}
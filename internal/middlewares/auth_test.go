@@ -0,0 +1,99 @@
+package middlewares
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/services"
+	"fleet-pulse-users-service/internal/testdb"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// testKeyEncryptionKey encrypts signing keys in tests, "0123456789abcdef" twice.
+const testKeyEncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+// tenantSeen authenticates the token and returns the tenant the request was scoped to.
+func tenantSeen(t *testing.T, db *gorm.DB, accessToken string) (companyID *uuid.UUID, scoped bool) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/tenant", JWTAuthMiddleware(services.NewAuthService(db)), func(c *gin.Context) {
+		companyID, scoped = internal.TenantFromContext(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	return companyID, scoped
+}
+
+func initTokenServices(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	config.Get().Auth.JwtKeyEncryptionKey = testKeyEncryptionKey
+	if _, err := services.InitSigningKeys(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.InitTokenRevocations(db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientSessionOfUserWithoutCompanyIsTenantScoped(t *testing.T) {
+	db := testdb.Open(t)
+	initTokenServices(t, db)
+	user := &models.User{FirstName: "Pat", LastName: "Planner", Email: "pat@example.com"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	client, _, err := services.NewClientService(db).CreateClient(services.ClientRegistration{
+		Name:         "Dispatch board",
+		Scopes:       []string{"openid"},
+		RedirectURIs: []string{"https://dispatch.example.com/callback"},
+		Public:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, _, _, err := services.NewAuthService(db).StartClientSession(user, client, []string{"openid"}, services.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	companyID, scoped := tenantSeen(t, db, accessToken)
+	if !scoped || companyID != nil {
+		t.Fatalf("got tenant %v (scoped %v), want the rows without a company", companyID, scoped)
+	}
+}
+
+func TestClientCredentialsWithoutCompanyAreNotTenantScoped(t *testing.T) {
+	db := testdb.Open(t)
+	initTokenServices(t, db)
+	client, secret, err := services.NewClientService(db).CreateClient(services.ClientRegistration{Name: "Telemetry ingestion"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := services.NewOAuthService(db).Token(
+		services.ClientCredentials{ClientID: client.ClientID, ClientSecret: secret},
+		schemas.TokenRequest{GrantType: services.GrantTypeClientCredentials},
+		services.ClientInfo{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if companyID, scoped := tenantSeen(t, db, response.AccessToken); scoped {
+		t.Fatalf("got tenant %v, want the platform client unscoped", companyID)
+	}
+}
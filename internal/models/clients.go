@@ -0,0 +1,39 @@
+package models
+
+import (
+	"fleet-pulse-users-service/internal"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Client is a caller of the OAuth endpoints: a background worker using its own identity,
+// or an application users sign in to. Clients bound to a company only act within it;
+// unbound clients serve the whole platform. Public clients, such as mobile apps, cannot
+// keep a secret and authenticate with PKCE alone.
+type Client struct {
+	ID           uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	ClientID     string     `gorm:"not null;uniqueIndex"`
+	SecretHash   string     `gorm:"not null"`
+	Name         string     `gorm:"not null"`
+	Scopes       string     `gorm:"not null;default:''"`
+	RedirectURIs string     `gorm:"column:redirect_uris;not null;default:''"`
+	Public       bool       `gorm:"not null;default:false"`
+	CompanyID    *uuid.UUID `gorm:"type:uuid;index"`
+	Company      *Company   `gorm:"foreignKey:CompanyID;references:ID;constraint:OnDelete:CASCADE"`
+	internal.Metadata
+}
+
+func (Client) TenantColumn() string {
+	return "company_id"
+}
+
+// ScopeList returns the scopes the client may request, stored space-separated.
+func (c *Client) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// RedirectURIList returns the registered redirect URIs, stored space-separated.
+func (c *Client) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"fleet-pulse-users-service/internal"
+
+	"github.com/google/uuid"
+)
+
+type Company struct {
+	ID   uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	Name string    `gorm:"not null"`
+	internal.Metadata
+}
+
+func (Company) TenantColumn() string {
+	return "id"
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"fleet-pulse-users-service/internal"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	InviteStatusPending  = "pending"
+	InviteStatusAccepted = "accepted"
+	InviteStatusRevoked  = "revoked"
+	InviteStatusExpired  = "expired"
+)
+
+// Invite tracks an invitation email sent to a user to set their password. Only the latest
+// invite of a user is pending; sending a new one revokes the previous one.
+type Invite struct {
+	ID          uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID      uuid.UUID  `gorm:"not null;index"`
+	User        User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+	Email       string     `gorm:"not null"`
+	CompanyID   *uuid.UUID `gorm:"type:uuid;index"`
+	InvitedByID *uuid.UUID `gorm:"type:uuid"`
+	// NonceHash binds the emailed token to this invite. It is cleared once the invite is accepted,
+	// so a token can only be used once.
+	NonceHash  string    `gorm:"not null"`
+	Status     string    `gorm:"not null;default:pending"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	AcceptedAt *time.Time
+	RevokedAt  *time.Time
+	internal.Metadata
+}
+
+func (Invite) TenantColumn() string {
+	return "company_id"
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fleet-pulse-users-service/internal"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTPFactor is a user's authenticator app. It only guards sign-ins once ConfirmedAt is set,
+// which happens when the user proves the app works by entering a first code.
+type TOTPFactor struct {
+	ID     uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID uuid.UUID `gorm:"not null;uniqueIndex"`
+	User   User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+	// Secret is the base32 shared secret. Unlike passwords it cannot be hashed, codes are
+	// derived from it.
+	Secret      string `gorm:"not null"`
+	ConfirmedAt *time.Time
+	// LastUsedStep is the time step of the last accepted code, so a code works only once.
+	LastUsedStep int64 `gorm:"not null;default:0"`
+	// Wrong codes are counted per user across every place a code is asked for. Too many
+	// within a window lock the factor until LockedUntil.
+	FailedAttempts     int `gorm:"not null;default:0"`
+	FailureWindowStart *time.Time
+	LockedUntil        *time.Time
+	internal.Metadata
+}
+
+// RecoveryCode is a single use code that replaces a TOTP code when the authenticator is lost.
+// Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID    uuid.UUID `gorm:"not null;index"`
+	User      User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+	CodeHash  string    `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAChallenge is handed out by a password sign-in of a user with two-factor authentication.
+// It is exchanged together with a code for the token pair.
+type MFAChallenge struct {
+	ID         uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID     uuid.UUID `gorm:"not null;index"`
+	User       User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+	TokenHash  string    `gorm:"not null;uniqueIndex"`
+	DeviceName string
+	// Attempts counts wrong codes, the challenge is dropped after too many.
+	Attempts  int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
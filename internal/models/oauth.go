@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fleet-pulse-users-service/internal"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuthorizationCode is issued by the authorize endpoint and exchanged once for tokens.
+// Only the hash of the code is stored, and the PKCE challenge binds it to the app instance
+// that started the flow.
+type AuthorizationCode struct {
+	ID            uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	CodeHash      string     `gorm:"not null;uniqueIndex"`
+	ClientID      string     `gorm:"not null;index"`
+	UserID        uuid.UUID  `gorm:"not null;index"`
+	User          User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+	RedirectURI   string     `gorm:"not null"`
+	Scopes        string     `gorm:"not null;default:''"`
+	CodeChallenge string     `gorm:"not null"`
+	Nonce         string     `gorm:"not null;default:''"`
+	SessionID     *uuid.UUID `gorm:"type:uuid"`
+	ExpiresAt     time.Time  `gorm:"not null"`
+	ConsumedAt    *time.Time
+	internal.Metadata
+}
+
+// ScopeList returns the scopes granted with the code, stored space-separated.
+func (a *AuthorizationCode) ScopeList() []string {
+	return strings.Fields(a.Scopes)
+}
+
+// OAuthConsent records the scopes a user granted to a client, so that they are not asked again.
+type OAuthConsent struct {
+	ID       uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID   uuid.UUID `gorm:"not null;uniqueIndex:idx_oauth_consents_user_client"`
+	ClientID string    `gorm:"not null;uniqueIndex:idx_oauth_consents_user_client"`
+	Scopes   string    `gorm:"not null;default:''"`
+	internal.Metadata
+}
+
+// ScopeList returns the granted scopes, stored space-separated.
+func (c *OAuthConsent) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"fleet-pulse-users-service/internal"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OutboxKindEmail = "email"
+
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	// OutboxStatusDead marks messages that kept failing and are no longer retried.
+	OutboxStatusDead = "dead"
+)
+
+// OutboxMessage is an outgoing message written in the same transaction as the change that
+// caused it, and delivered by the outbox dispatcher once that transaction has committed.
+// The payload is cleared once the message is sent.
+type OutboxMessage struct {
+	ID            uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	Kind          string    `gorm:"not null"`
+	Payload       string    `gorm:"type:jsonb;not null"`
+	Status        string    `gorm:"not null;default:pending"`
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null"`
+	LastError     string    `gorm:"not null;default:''"`
+	SentAt        *time.Time
+	internal.Metadata
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox"
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevokedAccessToken denies a single access token, by jti, until it expires on its own.
+type RevokedAccessToken struct {
+	JTI       string     `gorm:"primaryKey;column:jti"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// RevokedSession denies every access token issued to a signed out session. It is kept until
+// the last of them expires on its own.
+type RevokedSession struct {
+	SessionID uuid.UUID  `gorm:"primaryKey;type:uuid"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	CreatedAt time.Time
+}
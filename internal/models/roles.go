@@ -0,0 +1,29 @@
+package models
+
+import (
+	"fleet-pulse-users-service/internal"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoleFleetAdmin = "fleet-admin"
+	RoleDispatcher = "dispatcher"
+	RoleDriver     = "driver"
+	RoleViewer     = "viewer"
+)
+
+type Role struct {
+	ID          uuid.UUID    `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	Name        string       `gorm:"not null;uniqueIndex"`
+	Description string       `gorm:"not null"`
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+	internal.Metadata
+}
+
+type Permission struct {
+	ID          uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	Name        string    `gorm:"not null;uniqueIndex"`
+	Description string    `gorm:"not null"`
+	internal.Metadata
+}
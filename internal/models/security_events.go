@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SecurityEventRefreshTokenReuse           = "refresh_token_reuse"
+	SecurityEventAuthorizationCodeReuse      = "authorization_code_reuse"
+	SecurityEventPasskeyCloneWarning         = "passkey_clone_warning"
+	SecurityEventPasskeyRegistered           = "passkey_registered"
+	SecurityEventPasskeyRemoved              = "passkey_removed"
+	SecurityEventPasswordReset               = "password_reset"
+	SecurityEventPasswordChanged             = "password_changed"
+	SecurityEventMFAEnabled                  = "mfa_enabled"
+	SecurityEventMFADisabled                 = "mfa_disabled"
+	SecurityEventMFARecoveryCodesRegenerated = "mfa_recovery_codes_regenerated"
+	SecurityEventMFARecoveryCodeUsed         = "mfa_recovery_code_used"
+	SecurityEventMFAChallengeLocked          = "mfa_challenge_locked"
+	SecurityEventMFALocked                   = "mfa_locked"
+)
+
+// SecurityEvent is an append-only record of suspicious or security relevant activity.
+type SecurityEvent struct {
+	ID        uuid.UUID              `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	Type      string                 `gorm:"not null;index"`
+	UserID    *uuid.UUID             `gorm:"type:uuid;index"`
+	CompanyID *uuid.UUID             `gorm:"type:uuid;index"`
+	Details   map[string]interface{} `gorm:"type:jsonb;serializer:json"`
+	CreatedAt time.Time
+}
+
+func (SecurityEvent) TenantColumn() string {
+	return "company_id"
+}
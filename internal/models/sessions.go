@@ -0,0 +1,35 @@
+package models
+
+import (
+	"fleet-pulse-users-service/internal"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a single signed in device. It owns the refresh token chain issued at login.
+// Sessions opened through an OAuth client are limited to the scopes the user granted it.
+type Session struct {
+	ID         uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID     uuid.UUID  `gorm:"not null;index"`
+	User       User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+	CompanyID  *uuid.UUID `gorm:"type:uuid;index"`
+	DeviceName string
+	UserAgent  string
+	IPAddress  string
+	ClientID   *string
+	Scopes     string    `gorm:"not null;default:''"`
+	LastUsedAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	internal.Metadata
+}
+
+func (Session) TenantColumn() string {
+	return "company_id"
+}
+
+// ScopeList returns the scopes granted to the session's client, stored space-separated.
+func (s *Session) ScopeList() []string {
+	return strings.Fields(s.Scopes)
+}
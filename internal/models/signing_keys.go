@@ -0,0 +1,22 @@
+package models
+
+import (
+	"fleet-pulse-users-service/internal"
+	"time"
+)
+
+const (
+	SigningKeyStatusActive  = "active"
+	SigningKeyStatusRetired = "retired"
+)
+
+// SigningKey is a persisted access token signing key. Exactly one key is active at a time,
+// retired keys are kept for verification until ExpiresAt.
+type SigningKey struct {
+	KID        string `gorm:"primaryKey;column:kid"`
+	PrivateKey string `gorm:"not null"`
+	Status     string `gorm:"not null"`
+	RetiredAt  *time.Time
+	ExpiresAt  *time.Time
+	internal.Metadata
+}
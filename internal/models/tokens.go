@@ -7,11 +7,24 @@ import (
 	"github.com/google/uuid"
 )
 
+// RefreshToken is one link of a rotation chain. Every refresh consumes the presented token
+// and issues a new one in the same family, so that a consumed token showing up again
+// reveals that the chain was stolen.
 type RefreshToken struct {
-	ID        uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
-	UserID    uuid.UUID `gorm:"not null;index"`
-	User      User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
-	Token     string    `gorm:"not null;uniqueIndex"`
-	ExpiresAt time.Time `gorm:"not null"`
+	ID         uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID     uuid.UUID  `gorm:"not null;index"`
+	User       User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+	CompanyID  *uuid.UUID `gorm:"type:uuid;index"`
+	FamilyID   uuid.UUID  `gorm:"type:uuid;not null;index"`
+	SessionID  *uuid.UUID `gorm:"type:uuid;index"`
+	Session    *Session   `gorm:"foreignKey:SessionID;references:ID;constraint:OnDelete:CASCADE"`
+	Token      string     `gorm:"not null;uniqueIndex"`
+	ExpiresAt  time.Time  `gorm:"not null"`
+	ConsumedAt *time.Time
+	RevokedAt  *time.Time
 	internal.Metadata
 }
+
+func (RefreshToken) TenantColumn() string {
+	return "company_id"
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fleet-pulse-users-service/internal"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -12,5 +13,16 @@ type User struct {
 	LastName  string    `gorm:"not null"`
 	Email     string    `gorm:"not null;uniqueIndex"`
 	Password  string
+	CompanyID *uuid.UUID `gorm:"type:uuid;index"`
+	Company   *Company   `gorm:"foreignKey:CompanyID;references:ID;constraint:OnDelete:SET NULL"`
+	Roles     []Role     `gorm:"many2many:user_roles"`
+	// Access tokens issued before this moment are rejected.
+	TokensValidAfter *time.Time
+	// AcceptedAt is when the user accepted their invite and set a password.
+	AcceptedAt *time.Time
 	internal.Metadata
 }
+
+func (User) TenantColumn() string {
+	return "company_id"
+}
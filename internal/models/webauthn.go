@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fleet-pulse-users-service/internal"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+)
+
+// WebAuthnCredential is a passkey of a user. Only its public key is stored, the private key
+// never leaves the authenticator.
+type WebAuthnCredential struct {
+	ID     uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID uuid.UUID `gorm:"not null;index"`
+	User   User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+	// CredentialID is the ID the authenticator assigned, it identifies the passkey in sign-ins.
+	CredentialID    []byte `gorm:"not null;uniqueIndex"`
+	PublicKey       []byte `gorm:"not null"`
+	AttestationType string `gorm:"not null;default:''"`
+	// Transports is a space separated list such as "internal hybrid".
+	Transports string `gorm:"not null;default:''"`
+	AAGUID     []byte
+	// SignCount is the signature counter of the last sign-in. Authenticators that count
+	// increase it with every signature, a lower one hints at a cloned authenticator.
+	SignCount      int64 `gorm:"not null;default:0"`
+	CloneWarning   bool  `gorm:"not null;default:false"`
+	BackupEligible bool  `gorm:"not null;default:false"`
+	BackupState    bool  `gorm:"not null;default:false"`
+	// Name is picked by the user to tell their passkeys apart.
+	Name       string `gorm:"not null;default:''"`
+	LastUsedAt *time.Time
+	internal.Metadata
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// WebAuthnSession holds the challenge of a registration or sign-in ceremony between its begin
+// and finish requests. UserID is empty for sign-ins where the authenticator picks the passkey.
+type WebAuthnSession struct {
+	ID       uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	UserID   *uuid.UUID `gorm:"type:uuid;index"`
+	User     *User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+	Ceremony string     `gorm:"not null"`
+	// Data is the JSON encoded session data of the WebAuthn library.
+	Data       []byte `gorm:"not null"`
+	DeviceName string
+	ExpiresAt  time.Time `gorm:"not null"`
+	CreatedAt  time.Time
+}
+
+func (WebAuthnSession) TableName() string {
+	return "webauthn_sessions"
+}
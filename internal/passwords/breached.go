@@ -0,0 +1,53 @@
+package passwords
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+//go:embed breached_sha1.txt
+var breachedList []byte
+
+// breachedHashes holds the decoded hashes of the bundled list, sorted for binary search.
+// At 20 bytes a hash this keeps a list of Pwned Passwords' size small in memory.
+var breachedHashes = loadBreachedHashes(breachedList)
+
+// loadBreachedHashes reads one hex SHA-1 hash per line. A ":COUNT" suffix, as in the
+// Pwned Passwords downloads, is ignored, and so are comments and malformed lines.
+func loadBreachedHashes(list []byte) [][sha1.Size]byte {
+	var hashes [][sha1.Size]byte
+	scanner := bufio.NewScanner(bytes.NewReader(list))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if len(line) != sha1.Size*2 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var hash [sha1.Size]byte
+		if _, err := hex.Decode(hash[:], []byte(line)); err != nil {
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+	slices.SortFunc(hashes, compareHashes)
+	return slices.Compact(hashes)
+}
+
+// IsBreached reports whether the password, or its lower-cased form, is in the bundled list.
+func IsBreached(password string) bool {
+	return inBreachedList(password) || inBreachedList(strings.ToLower(password))
+}
+
+func inBreachedList(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	_, ok := slices.BinarySearchFunc(breachedHashes, sum, compareHashes)
+	return ok
+}
+
+func compareHashes(a, b [sha1.Size]byte) int {
+	return bytes.Compare(a[:], b[:])
+}
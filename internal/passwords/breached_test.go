@@ -0,0 +1,25 @@
+package passwords
+
+import "testing"
+
+func TestIsBreached(t *testing.T) {
+	for _, password := range []string{"password", "Welcome123!", "P@ssw0rd123", "Admin@12345", "Summer2024!", "QWERTY123"} {
+		if !IsBreached(password) {
+			t.Errorf("%q was not found in the bundled list", password)
+		}
+	}
+	if IsBreached("Correct-Horse-Battery-9") {
+		t.Error("an uncommon password was found in the bundled list")
+	}
+}
+
+func TestLoadBreachedHashesReadsPwnedPasswordsExports(t *testing.T) {
+	list := []byte("# comment\n" +
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:10434004\n" +
+		"5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8\n" +
+		"not a hash\n")
+	hashes := loadBreachedHashes(list)
+	if len(hashes) != 1 || hashes[0][0] != 0x5B {
+		t.Fatalf("got %d hashes, want the SHA-1 of \"password\" once", len(hashes))
+	}
+}
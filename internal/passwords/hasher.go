@@ -0,0 +1,205 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fleet-pulse-users-service/internal/config"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher turns passwords into self-describing encoded hashes, so the parameters a hash was
+// made with travel with it.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Identifies reports whether the encoded hash was made by this kind of hasher.
+	Identifies(encoded string) bool
+	Verify(encoded, password string) (bool, error)
+	// NeedsRehash reports whether the encoded hash was made with outdated parameters.
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2idHasher encodes hashes in the PHC string format,
+// e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>.
+type Argon2idHasher struct {
+	MemoryKiB   uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+const argon2idPrefix = "$argon2id$"
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.MemoryKiB, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, h.MemoryKiB, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) Identifies(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+func (h Argon2idHasher) Verify(encoded, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.MemoryKiB, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, candidate) == 1, nil
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.MemoryKiB != h.MemoryKiB ||
+		params.Iterations != h.Iterations ||
+		params.Parallelism != h.Parallelism ||
+		uint32(len(salt)) != h.SaltLength ||
+		uint32(len(key)) != h.KeyLength
+}
+
+func decodeArgon2id(encoded string) (params Argon2idHasher, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("malformed argon2id hash")
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	// argon2.IDKey panics on zero parallelism, and zero memory or iterations is not a hash.
+	if params.MemoryKiB == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d", params.MemoryKiB, params.Iterations, params.Parallelism)
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id hash: %w", err)
+	}
+	// An empty hash would match the empty key derived for any password.
+	if len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("empty argon2id hash")
+	}
+	return params, salt, key, nil
+}
+
+// BcryptHasher verifies hashes stored before argon2id became the default. It can still
+// hash new passwords when PASSWORD_HASHER=bcrypt.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h BcryptHasher) Identifies(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// HasherSet hashes new passwords with the preferred hasher and verifies hashes made by any
+// of its hashers.
+type HasherSet struct {
+	Preferred Hasher
+	Legacy    []Hasher
+}
+
+func (s *HasherSet) Hash(password string) (string, error) {
+	return s.Preferred.Hash(password)
+}
+
+// Verify checks the password against the encoded hash. needsRehash is true when the password
+// matched but the hash should be replaced with one from the preferred hasher.
+func (s *HasherSet) Verify(encoded, password string) (ok bool, needsRehash bool) {
+	for _, hasher := range append([]Hasher{s.Preferred}, s.Legacy...) {
+		if !hasher.Identifies(encoded) {
+			continue
+		}
+		ok, err := hasher.Verify(encoded, password)
+		if err != nil {
+			log.Printf("Failed to verify password hash: %v", err)
+			return false, false
+		}
+		if !ok {
+			return false, false
+		}
+		return true, hasher != s.Preferred || s.Preferred.NeedsRehash(encoded)
+	}
+	return false, false
+}
+
+var (
+	hashers     *HasherSet
+	hashersOnce sync.Once
+)
+
+// Hashers returns the process-wide hasher set selected by PASSWORD_HASHER.
+func Hashers() *HasherSet {
+	hashersOnce.Do(func() {
+		hashers = NewHasherSet(config.Get().Auth)
+	})
+	return hashers
+}
+
+func NewHasherSet(settings config.AuthConfig) *HasherSet {
+	argon2id := Argon2idHasher{
+		MemoryKiB:   uint32(settings.Argon2MemoryKiB),
+		Iterations:  uint32(settings.Argon2Iterations),
+		Parallelism: uint8(settings.Argon2Parallelism),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+	bcryptHasher := BcryptHasher{Cost: settings.BcryptCost}
+
+	switch settings.PasswordHasher {
+	case "argon2id":
+		return &HasherSet{Preferred: argon2id, Legacy: []Hasher{bcryptHasher}}
+	case "bcrypt":
+		return &HasherSet{Preferred: bcryptHasher, Legacy: []Hasher{argon2id}}
+	default:
+		log.Fatalf("Unknown PASSWORD_HASHER %q", settings.PasswordHasher)
+		return nil
+	}
+}
@@ -0,0 +1,122 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id keeps the cost low so the tests stay fast.
+var testArgon2id = Argon2idHasher{MemoryKiB: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	encoded, err := testArgon2id.Hash("Correct-Horse-Battery-9")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") || !testArgon2id.Identifies(encoded) {
+		t.Fatalf("Hash() = %q, want a PHC string with the hasher's parameters", encoded)
+	}
+
+	if ok, err := testArgon2id.Verify(encoded, "Correct-Horse-Battery-9"); err != nil || !ok {
+		t.Errorf("Verify(correct password) = %v, %v, want true", ok, err)
+	}
+	if ok, err := testArgon2id.Verify(encoded, "correct-horse-battery-9"); err != nil || ok {
+		t.Errorf("Verify(wrong password) = %v, %v, want false", ok, err)
+	}
+
+	other, err := testArgon2id.Hash("Correct-Horse-Battery-9")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if other == encoded {
+		t.Error("hashing the same password twice gave the same hash, salts are not random")
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	encoded, err := testArgon2id.Hash("Correct-Horse-Battery-9")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if testArgon2id.NeedsRehash(encoded) {
+		t.Error("NeedsRehash() = true for a hash made with the current parameters")
+	}
+
+	tests := map[string]func(h *Argon2idHasher){
+		"memory":      func(h *Argon2idHasher) { h.MemoryKiB *= 2 },
+		"iterations":  func(h *Argon2idHasher) { h.Iterations++ },
+		"parallelism": func(h *Argon2idHasher) { h.Parallelism++ },
+		"salt length": func(h *Argon2idHasher) { h.SaltLength = 32 },
+		"key length":  func(h *Argon2idHasher) { h.KeyLength = 64 },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			hasher := testArgon2id
+			change(&hasher)
+			if !hasher.NeedsRehash(encoded) {
+				t.Errorf("NeedsRehash() = false after changing the %s", name)
+			}
+		})
+	}
+}
+
+func TestDecodeArgon2idRejectsMalformedHashes(t *testing.T) {
+	salt, key := "c2FsdHNhbHRzYWx0c2FsdA", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	if _, _, _, err := decodeArgon2id("$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$" + key); err != nil {
+		t.Fatalf("decodeArgon2id(valid hash) error = %v", err)
+	}
+
+	for name, encoded := range map[string]string{
+		"empty":            "",
+		"bcrypt":           "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		"argon2i":          "$argon2i$v=19$m=1024,t=1,p=1$" + salt + "$" + key,
+		"missing hash":     "$argon2id$v=19$m=1024,t=1,p=1$" + salt,
+		"extra part":       "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$" + key + "$x",
+		"bad version":      "$argon2id$v=x$m=1024,t=1,p=1$" + salt + "$" + key,
+		"other version":    "$argon2id$v=16$m=1024,t=1,p=1$" + salt + "$" + key,
+		"bad parameters":   "$argon2id$v=19$m=1024;t=1;p=1$" + salt + "$" + key,
+		"missing p":        "$argon2id$v=19$m=1024,t=1$" + salt + "$" + key,
+		"salt not base64":  "$argon2id$v=19$m=1024,t=1,p=1$not*base64$" + key,
+		"hash not base64":  "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$not*base64",
+		"padded base64":    "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "==$" + key,
+		"zero memory":      "$argon2id$v=19$m=0,t=1,p=1$" + salt + "$" + key,
+		"zero iterations":  "$argon2id$v=19$m=1024,t=0,p=1$" + salt + "$" + key,
+		"zero parallelism": "$argon2id$v=19$m=1024,t=1,p=0$" + salt + "$" + key,
+		"empty hash":       "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, _, _, err := decodeArgon2id(encoded); err == nil {
+				t.Errorf("decodeArgon2id(%q) succeeded", encoded)
+			}
+			if ok, _ := testArgon2id.Verify(encoded, "Correct-Horse-Battery-9"); ok {
+				t.Errorf("Verify(%q) = true", encoded)
+			}
+		})
+	}
+}
+
+func TestHasherSetUpgradesLegacyHashes(t *testing.T) {
+	legacy := BcryptHasher{Cost: bcrypt.MinCost}
+	set := &HasherSet{Preferred: testArgon2id, Legacy: []Hasher{legacy}}
+
+	bcryptHash, err := legacy.Hash("Correct-Horse-Battery-9")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if ok, needsRehash := set.Verify(bcryptHash, "Correct-Horse-Battery-9"); !ok || !needsRehash {
+		t.Errorf("Verify(bcrypt hash) = %v, %v, want true, true", ok, needsRehash)
+	}
+	if ok, _ := set.Verify(bcryptHash, "wrong"); ok {
+		t.Error("Verify(bcrypt hash, wrong password) = true")
+	}
+
+	argon2idHash, err := set.Hash("Correct-Horse-Battery-9")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if ok, needsRehash := set.Verify(argon2idHash, "Correct-Horse-Battery-9"); !ok || needsRehash {
+		t.Errorf("Verify(argon2id hash) = %v, %v, want true, false", ok, needsRehash)
+	}
+}
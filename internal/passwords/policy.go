@@ -0,0 +1,134 @@
+package passwords
+
+import (
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	ViolationTooShort         = "too_short"
+	ViolationTooLong          = "too_long"
+	ViolationCharacterClasses = "too_few_character_classes"
+	ViolationContainsPersonal = "contains_personal_info"
+	ViolationBreached         = "breached"
+)
+
+// Names shorter than this are too likely to show up by accident to be banned.
+const minPersonalInfoTokenLength = 3
+
+// Policy decides which passwords users may set.
+type Policy struct {
+	MinLength           int
+	MaxLength           int
+	MinCharacterClasses int
+	ForbidPersonalInfo  bool
+	CheckBreached       bool
+}
+
+// PersonalInfo is what a password must not be built from.
+type PersonalInfo struct {
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+var (
+	instance *Policy
+	once     sync.Once
+)
+
+// Get returns the process-wide policy configured through the PASSWORD_* settings.
+func Get() *Policy {
+	once.Do(func() {
+		instance = NewPolicy(config.Get().Auth)
+	})
+	return instance
+}
+
+func NewPolicy(settings config.AuthConfig) *Policy {
+	return &Policy{
+		MinLength:           settings.PasswordMinLength,
+		MaxLength:           settings.PasswordMaxLength,
+		MinCharacterClasses: settings.PasswordMinCharacterClasses,
+		ForbidPersonalInfo:  settings.PasswordForbidPersonalInfo,
+		CheckBreached:       settings.PasswordCheckBreached,
+	}
+}
+
+// Validate checks the password against every rule. It returns an *errors.PasswordPolicyError
+// listing all unmet rules, or nil if the password is acceptable.
+func (p *Policy) Validate(password string, info PersonalInfo) error {
+	var violations []errors.PasswordViolation
+	add := func(code, message string) {
+		violations = append(violations, errors.PasswordViolation{Code: code, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add(ViolationTooShort, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	// Bytes rather than characters, since that is what hashers such as bcrypt limit.
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		add(ViolationTooLong, fmt.Sprintf("must be at most %d bytes long", p.MaxLength))
+	}
+	if characterClasses(password) < p.MinCharacterClasses {
+		add(ViolationCharacterClasses, fmt.Sprintf(
+			"must mix at least %d of lower case letters, upper case letters, digits and symbols", p.MinCharacterClasses,
+		))
+	}
+	if p.ForbidPersonalInfo && containsPersonalInfo(password, info) {
+		add(ViolationContainsPersonal, "must not contain your email or name")
+	}
+	if p.CheckBreached && password != "" && IsBreached(password) {
+		add(ViolationBreached, "is too common or appeared in a data breach")
+	}
+
+	if len(violations) > 0 {
+		return &errors.PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+func containsPersonalInfo(password string, info PersonalInfo) bool {
+	password = strings.ToLower(password)
+	email := strings.ToLower(info.Email)
+	if email != "" && password == email {
+		return true
+	}
+	localPart, _, _ := strings.Cut(email, "@")
+	for _, token := range []string{localPart, info.FirstName, info.LastName} {
+		token = strings.ToLower(strings.TrimSpace(token))
+		if utf8.RuneCountInString(token) >= minPersonalInfoTokenLength && strings.Contains(password, token) {
+			return true
+		}
+	}
+	return false
+}
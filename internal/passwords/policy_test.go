@@ -0,0 +1,85 @@
+package passwords
+
+import (
+	stderrors "errors"
+	"fleet-pulse-users-service/internal/errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateListsEveryViolation(t *testing.T) {
+	policy := &Policy{
+		MinLength:           10,
+		MaxLength:           72,
+		MinCharacterClasses: 3,
+		ForbidPersonalInfo:  true,
+		CheckBreached:       true,
+	}
+	info := PersonalInfo{Email: "pat@example.com", FirstName: "Pat", LastName: "Planner"}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{
+			name:     "acceptable",
+			password: "Correct-Horse-Battery-9",
+		},
+		{
+			name:     "empty",
+			password: "",
+			want:     []string{ViolationTooShort, ViolationCharacterClasses},
+		},
+		{
+			name:     "short common password",
+			password: "password",
+			want:     []string{ViolationTooShort, ViolationCharacterClasses, ViolationBreached},
+		},
+		{
+			name:     "short with name",
+			password: "patrick",
+			want:     []string{ViolationTooShort, ViolationCharacterClasses, ViolationContainsPersonal},
+		},
+		{
+			name:     "long with surname",
+			password: "Planner-" + strings.Repeat("a", 70),
+			want:     []string{ViolationTooLong, ViolationContainsPersonal},
+		},
+		{
+			name:     "email",
+			password: "Pat@Example.com",
+			want:     []string{ViolationContainsPersonal},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.Validate(test.password, info)
+			if test.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+
+			if !stderrors.Is(err, errors.ErrWeakPassword) {
+				t.Fatalf("Validate() = %v, want ErrWeakPassword", err)
+			}
+			var policyErr *errors.PasswordPolicyError
+			if !stderrors.As(err, &policyErr) {
+				t.Fatalf("Validate() = %T, want *errors.PasswordPolicyError", err)
+			}
+			codes := make([]string, 0, len(policyErr.Violations))
+			for _, violation := range policyErr.Violations {
+				if violation.Message == "" {
+					t.Errorf("violation %s has no message", violation.Code)
+				}
+				codes = append(codes, violation.Code)
+			}
+			if !slices.Equal(codes, test.want) {
+				t.Errorf("violations = %v, want %v", codes, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ClientRepository struct {
+	*internal.BaseRepository[models.Client, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewClientRepository(db *gorm.DB) *ClientRepository {
+	baseRepo := internal.NewBaseRepository[models.Client, uuid.UUID](db)
+	return &ClientRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+func (r *ClientRepository) GetByClientID(clientID string) (*models.Client, error) {
+	var client models.Client
+	if err := r.Scoped().Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *ClientRepository) List() ([]models.Client, error) {
+	var clients []models.Client
+	if err := r.Scoped().Order("created_at").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CompanyRepository struct {
+	*internal.BaseRepository[models.Company, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewCompanyRepository(db *gorm.DB) *CompanyRepository {
+	baseRepo := internal.NewBaseRepository[models.Company, uuid.UUID](db)
+	return &CompanyRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+func (r *CompanyRepository) List() ([]models.Company, error) {
+	var companies []models.Company
+	if err := r.Scoped().Order("name").Find(&companies).Error; err != nil {
+		return nil, err
+	}
+	return companies, nil
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type InviteRepository struct {
+	*internal.BaseRepository[models.Invite, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewInviteRepository(db *gorm.DB) *InviteRepository {
+	baseRepo := internal.NewBaseRepository[models.Invite, uuid.UUID](db)
+	return &InviteRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+// GetCompanyInvite returns the invite only if it belongs to the company.
+func (r *InviteRepository) GetCompanyInvite(companyID, inviteID uuid.UUID) (*models.Invite, error) {
+	var invite models.Invite
+	if err := r.Scoped().Where("id = ? AND company_id = ?", inviteID, companyID).First(&invite).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// ListByCompany returns the company's invites with the given status, newest first.
+// An empty status returns invites of every status.
+func (r *InviteRepository) ListByCompany(companyID uuid.UUID, status string) ([]models.Invite, error) {
+	query := r.Scoped().Where("company_id = ?", companyID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var invites []models.Invite
+	if err := query.Order("created_at DESC").Find(&invites).Error; err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+// ExpireOverdue moves pending invites past their expiry to the expired status.
+func (r *InviteRepository) ExpireOverdue(now time.Time) error {
+	return r.Scoped().Model(&models.Invite{}).
+		Where("status = ? AND expires_at <= ?", models.InviteStatusPending, now).
+		Update("status", models.InviteStatusExpired).Error
+}
+
+// RevokePendingForUser revokes every pending invite of the user.
+func (r *InviteRepository) RevokePendingForUser(userID uuid.UUID) error {
+	return r.Scoped().Model(&models.Invite{}).
+		Where("user_id = ? AND status = ?", userID, models.InviteStatusPending).
+		Updates(map[string]interface{}{"status": models.InviteStatusRevoked, "revoked_at": time.Now()}).Error
+}
+
+// Consume marks a pending invite accepted if the nonce still matches. Only one caller can
+// consume an invite, concurrent or replayed attempts get false.
+func (r *InviteRepository) Consume(invite *models.Invite, nonceHash string, now time.Time) (bool, error) {
+	result := r.Scoped().Model(&models.Invite{}).
+		Where("id = ? AND nonce_hash = ? AND nonce_hash <> '' AND status = ? AND expires_at > ?",
+			invite.ID, nonceHash, models.InviteStatusPending, now).
+		Updates(map[string]interface{}{"status": models.InviteStatusAccepted, "accepted_at": now, "nonce_hash": ""})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return false, nil
+	}
+	invite.Status = models.InviteStatusAccepted
+	invite.AcceptedAt = &now
+	invite.NonceHash = ""
+	return true, nil
+}
+
+// SetStatus moves the invite to the given status.
+func (r *InviteRepository) SetStatus(invite *models.Invite, status string) error {
+	updates := map[string]interface{}{"status": status}
+	now := time.Now()
+	if status == models.InviteStatusRevoked {
+		updates["revoked_at"] = now
+	}
+	if err := r.Scoped().Model(invite).Updates(updates).Error; err != nil {
+		return err
+	}
+	invite.Status = status
+	return nil
+}
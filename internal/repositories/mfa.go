@@ -0,0 +1,215 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TOTPFactorRepository struct {
+	*internal.BaseRepository[models.TOTPFactor, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewTOTPFactorRepository(db *gorm.DB) *TOTPFactorRepository {
+	baseRepo := internal.NewBaseRepository[models.TOTPFactor, uuid.UUID](db)
+	return &TOTPFactorRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+func (r *TOTPFactorRepository) GetUserFactor(userID uuid.UUID) (*models.TOTPFactor, error) {
+	var factor models.TOTPFactor
+	if err := r.Scoped().Where("user_id = ?", userID).First(&factor).Error; err != nil {
+		return nil, err
+	}
+	return &factor, nil
+}
+
+// HasConfirmedFactor reports whether sign-ins of the user need a second factor.
+func (r *TOTPFactorRepository) HasConfirmedFactor(userID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.Scoped().Model(&models.TOTPFactor{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// UseStep records that a code of the time step was accepted. It returns false when a code
+// of this or a later step was accepted already, which makes every code single use.
+func (r *TOTPFactorRepository) UseStep(factor *models.TOTPFactor, step int64) (bool, error) {
+	result := r.Scoped().Model(&models.TOTPFactor{}).
+		Where("id = ? AND last_used_step < ?", factor.ID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return false, nil
+	}
+	factor.LastUsedStep = step
+	return true, nil
+}
+
+// RecordFailure counts a wrong code and returns the number of wrong codes in the current
+// window, which starts afresh once the previous one is older than window. The count is read
+// back from the update itself, so concurrent requests cannot all see a low one.
+func (r *TOTPFactorRepository) RecordFailure(factor *models.TOTPFactor, now time.Time, window time.Duration) (int, error) {
+	windowExpired := "failure_window_start IS NULL OR failure_window_start <= ?"
+	err := r.Scoped().Model(factor).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "failed_attempts"}, {Name: "failure_window_start"}}}).
+		Updates(map[string]interface{}{
+			"failed_attempts": gorm.Expr(
+				"CASE WHEN "+windowExpired+" THEN 1 ELSE failed_attempts + 1 END", now.Add(-window),
+			),
+			"failure_window_start": gorm.Expr(
+				"CASE WHEN "+windowExpired+" THEN ? ELSE failure_window_start END", now.Add(-window), now,
+			),
+		}).Error
+	if err != nil {
+		return 0, err
+	}
+	return factor.FailedAttempts, nil
+}
+
+// Lock refuses every code of the factor until the given time.
+func (r *TOTPFactorRepository) Lock(factor *models.TOTPFactor, until time.Time) error {
+	err := r.Scoped().Model(factor).Updates(map[string]interface{}{
+		"failed_attempts":      0,
+		"failure_window_start": nil,
+		"locked_until":         until,
+	}).Error
+	if err != nil {
+		return err
+	}
+	factor.FailedAttempts = 0
+	factor.FailureWindowStart = nil
+	factor.LockedUntil = &until
+	return nil
+}
+
+// ResetFailures forgets wrong codes once a correct one was entered.
+func (r *TOTPFactorRepository) ResetFailures(factor *models.TOTPFactor) error {
+	if factor.FailedAttempts == 0 && factor.LockedUntil == nil {
+		return nil
+	}
+	err := r.Scoped().Model(factor).Updates(map[string]interface{}{
+		"failed_attempts":      0,
+		"failure_window_start": nil,
+		"locked_until":         nil,
+	}).Error
+	if err != nil {
+		return err
+	}
+	factor.FailedAttempts = 0
+	factor.FailureWindowStart = nil
+	factor.LockedUntil = nil
+	return nil
+}
+
+func (r *TOTPFactorRepository) Confirm(factor *models.TOTPFactor, now time.Time) error {
+	if err := r.Scoped().Model(factor).Update("confirmed_at", now).Error; err != nil {
+		return err
+	}
+	factor.ConfirmedAt = &now
+	return nil
+}
+
+func (r *TOTPFactorRepository) DeleteUserFactor(userID uuid.UUID) error {
+	return r.Scoped().Where("user_id = ?", userID).Delete(&models.TOTPFactor{}).Error
+}
+
+type RecoveryCodeRepository struct {
+	*internal.BaseRepository[models.RecoveryCode, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) *RecoveryCodeRepository {
+	baseRepo := internal.NewBaseRepository[models.RecoveryCode, uuid.UUID](db)
+	return &RecoveryCodeRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+// ReplaceUserCodes swaps every recovery code of the user for the given hashes.
+func (r *RecoveryCodeRepository) ReplaceUserCodes(userID uuid.UUID, codeHashes []string) error {
+	if err := r.DeleteUserCodes(userID); err != nil {
+		return err
+	}
+	codes := make([]models.RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	return r.db.Create(&codes).Error
+}
+
+// Consume marks an unused code of the user used. It returns false when there is no such code.
+func (r *RecoveryCodeRepository) Consume(userID uuid.UUID, codeHash string, now time.Time) (bool, error) {
+	result := r.Scoped().Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *RecoveryCodeRepository) CountUnused(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.Scoped().Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *RecoveryCodeRepository) DeleteUserCodes(userID uuid.UUID) error {
+	return r.Scoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+type MFAChallengeRepository struct {
+	*internal.BaseRepository[models.MFAChallenge, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewMFAChallengeRepository(db *gorm.DB) *MFAChallengeRepository {
+	baseRepo := internal.NewBaseRepository[models.MFAChallenge, uuid.UUID](db)
+	return &MFAChallengeRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+func (r *MFAChallengeRepository) GetByTokenHash(tokenHash string, now time.Time) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	if err := r.Scoped().Where("token_hash = ? AND expires_at > ?", tokenHash, now).First(&challenge).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// RecordFailure counts a wrong code and returns the number of failed attempts so far. The count
+// is read back from the update itself, so concurrent requests cannot all see a low one.
+func (r *MFAChallengeRepository) RecordFailure(challenge *models.MFAChallenge) (int, error) {
+	err := r.Scoped().Model(challenge).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "attempts"}}}).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+	if err != nil {
+		return 0, err
+	}
+	return challenge.Attempts, nil
+}
+
+// Consume deletes the challenge. It returns false when another request consumed it first.
+func (r *MFAChallengeRepository) Consume(challenge *models.MFAChallenge) (bool, error) {
+	result := r.Scoped().Where("id = ?", challenge.ID).Delete(&models.MFAChallenge{})
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeleteExpired removes challenges that can no longer be completed.
+func (r *MFAChallengeRepository) DeleteExpired(now time.Time) error {
+	return r.Scoped().Where("expires_at <= ?", now).Delete(&models.MFAChallenge{}).Error
+}
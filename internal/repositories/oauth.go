@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AuthorizationCodeRepository struct {
+	*internal.BaseRepository[models.AuthorizationCode, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewAuthorizationCodeRepository(db *gorm.DB) *AuthorizationCodeRepository {
+	baseRepo := internal.NewBaseRepository[models.AuthorizationCode, uuid.UUID](db)
+	return &AuthorizationCodeRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+func (r *AuthorizationCodeRepository) GetByCode(codeHash string) (*models.AuthorizationCode, error) {
+	var code models.AuthorizationCode
+	if err := r.Scoped().Where("code_hash = ?", codeHash).First(&code).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// Consume marks the code as used. It reports false when the code was already consumed,
+// so that two concurrent exchanges cannot both succeed.
+func (r *AuthorizationCodeRepository) Consume(code *models.AuthorizationCode) (bool, error) {
+	result := r.Scoped().Model(&models.AuthorizationCode{}).
+		Where("id = ? AND consumed_at IS NULL", code.ID).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SetSession records the session the code was exchanged for.
+func (r *AuthorizationCodeRepository) SetSession(code *models.AuthorizationCode, sessionID uuid.UUID) error {
+	return r.Scoped().Model(code).Update("session_id", sessionID).Error
+}
+
+// DeleteExpired removes codes that can no longer be exchanged. Consumed codes are kept
+// until they expire so that a replay can still be recognized.
+func (r *AuthorizationCodeRepository) DeleteExpired(now time.Time) error {
+	return r.Scoped().Where("expires_at <= ?", now).Delete(&models.AuthorizationCode{}).Error
+}
+
+type OAuthConsentRepository struct {
+	*internal.BaseRepository[models.OAuthConsent, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewOAuthConsentRepository(db *gorm.DB) *OAuthConsentRepository {
+	baseRepo := internal.NewBaseRepository[models.OAuthConsent, uuid.UUID](db)
+	return &OAuthConsentRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+func (r *OAuthConsentRepository) GetUserConsent(userID uuid.UUID, clientID string) (*models.OAuthConsent, error) {
+	var consent models.OAuthConsent
+	if err := r.Scoped().Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error; err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// Save stores the consent, replacing the scopes of an earlier consent to the same client.
+func (r *OAuthConsentRepository) Save(consent *models.OAuthConsent) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"scopes": consent.Scopes, "updated_at": time.Now()}),
+	}).Create(consent).Error
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository struct {
+	*internal.BaseRepository[models.OutboxMessage, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	baseRepo := internal.NewBaseRepository[models.OutboxMessage, uuid.UUID](db)
+	return &OutboxRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+// ClaimDue leases up to limit pending messages that are due to the caller and counts the
+// attempt. Until the lease ends no other dispatcher picks them up; a dispatcher that dies
+// before recording the outcome leaves them to be retried afterwards. The claim is a short
+// transaction of its own, so nothing stays locked while the messages are delivered.
+func (r *OutboxRepository) ClaimDue(now time.Time, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+		ids := make([]uuid.UUID, 0, len(messages))
+		for i := range messages {
+			messages[i].Attempts++
+			messages[i].NextAttemptAt = now.Add(lease)
+			ids = append(ids, messages[i].ID)
+		}
+		return tx.Model(&models.OutboxMessage{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(lease),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// SaveAttempt stores the outcome of a delivery attempt claimed by ClaimDue. It reports false
+// when the message was claimed again in the meantime, after the lease ran out; the outcome
+// is then left to the newer attempt.
+func (r *OutboxRepository) SaveAttempt(message *models.OutboxMessage) (bool, error) {
+	result := r.db.Model(message).
+		Where("attempts = ?", message.Attempts).
+		Select("status", "payload", "next_attempt_at", "last_error", "sent_at").
+		Updates(message)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PasswordResetTokenRepository struct {
+	*internal.BaseRepository[models.PasswordResetToken, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewPasswordResetTokenRepository(db *gorm.DB) *PasswordResetTokenRepository {
+	baseRepo := internal.NewBaseRepository[models.PasswordResetToken, uuid.UUID](db)
+	return &PasswordResetTokenRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+func (r *PasswordResetTokenRepository) GetByTokenHash(tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	if err := r.Scoped().Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Consume marks an unused, unexpired token used. Only one caller can consume a token,
+// concurrent or replayed attempts get false.
+func (r *PasswordResetTokenRepository) Consume(token *models.PasswordResetToken, now time.Time) (bool, error) {
+	result := r.Scoped().Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return false, nil
+	}
+	token.UsedAt = &now
+	return true, nil
+}
+
+// DeleteUnusedForUser removes the user's outstanding tokens, so only the latest emailed link works.
+func (r *PasswordResetTokenRepository) DeleteUnusedForUser(userID uuid.UUID) error {
+	return r.Scoped().Where("user_id = ? AND used_at IS NULL", userID).Delete(&models.PasswordResetToken{}).Error
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevokedAccessTokenRepository struct {
+	*internal.BaseRepository[models.RevokedAccessToken, string]
+	db *gorm.DB
+}
+
+func NewRevokedAccessTokenRepository(db *gorm.DB) *RevokedAccessTokenRepository {
+	baseRepo := internal.NewBaseRepository[models.RevokedAccessToken, string](db)
+	return &RevokedAccessTokenRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+// Revoke denies the token. Revoking an already revoked token is not an error.
+func (r *RevokedAccessTokenRepository) Revoke(token *models.RevokedAccessToken) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+func (r *RevokedAccessTokenRepository) ListUnexpired(now time.Time) ([]models.RevokedAccessToken, error) {
+	var tokens []models.RevokedAccessToken
+	if err := r.Scoped().Where("expires_at > ?", now).Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *RevokedAccessTokenRepository) DeleteExpired(now time.Time) error {
+	if err := r.Scoped().Where("expires_at <= ?", now).Delete(&models.RevokedAccessToken{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("expires_at <= ?", now).Delete(&models.RevokedSession{}).Error
+}
+
+// RevokeSession denies the access tokens of the session. Revoking it twice is not an error.
+func (r *RevokedAccessTokenRepository) RevokeSession(session *models.RevokedSession) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(session).Error
+}
+
+func (r *RevokedAccessTokenRepository) ListUnexpiredSessions(now time.Time) ([]models.RevokedSession, error) {
+	var sessions []models.RevokedSession
+	if err := r.db.Where("expires_at > ?", now).Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// UserWatermark is the moment before which the user's access tokens are rejected.
+type UserWatermark struct {
+	ID               uuid.UUID
+	TokensValidAfter time.Time
+}
+
+// ListTokenWatermarks returns watermarks set after the given time. Older ones can only
+// reject tokens that have expired anyway.
+func (r *RevokedAccessTokenRepository) ListTokenWatermarks(since time.Time) ([]UserWatermark, error) {
+	var watermarks []UserWatermark
+	err := r.db.Model(&models.User{}).
+		Select("id, tokens_valid_after").
+		Where("tokens_valid_after > ?", since).
+		Scan(&watermarks).Error
+	if err != nil {
+		return nil, err
+	}
+	return watermarks, nil
+}
+
+// SetTokenWatermark rejects every access token issued to the user before the given time.
+// The user is not tenant-scoped here; callers resolve the user through a scoped lookup first.
+func (r *RevokedAccessTokenRepository) SetTokenWatermark(userID uuid.UUID, validAfter time.Time) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("tokens_valid_after", validAfter).Error
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RoleRepository struct {
+	*internal.BaseRepository[models.Role, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	baseRepo := internal.NewBaseRepository[models.Role, uuid.UUID](db)
+	return &RoleRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+func (r *RoleRepository) List() ([]models.Role, error) {
+	var roles []models.Role
+	if err := r.Scoped().Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) GetByNames(names []string) ([]models.Role, error) {
+	var roles []models.Role
+	if err := r.Scoped().Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) GetUserRoles(userID uuid.UUID) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.Scoped().
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) GetUserPermissions(userID uuid.UUID) ([]string, error) {
+	var permissions []string
+	err := r.db.Model(&models.Permission{}).
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Order("permissions.name").
+		Pluck("permissions.name", &permissions).Error
+	if err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// SetUserRoles replaces every role assigned to the user.
+func (r *RoleRepository) SetUserRoles(user *models.User, roles []models.Role) error {
+	return r.db.Model(user).Association("Roles").Replace(roles)
+}
+
+// GetPermissionNames returns which of the given names are existing permissions.
+func (r *RoleRepository) GetPermissionNames(names []string) ([]string, error) {
+	var permissions []string
+	err := r.db.Model(&models.Permission{}).
+		Where("name IN ?", names).
+		Order("name").
+		Pluck("name", &permissions).Error
+	if err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
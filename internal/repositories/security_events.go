@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SecurityEventRepository struct {
+	*internal.BaseRepository[models.SecurityEvent, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewSecurityEventRepository(db *gorm.DB) *SecurityEventRepository {
+	baseRepo := internal.NewBaseRepository[models.SecurityEvent, uuid.UUID](db)
+	return &SecurityEventRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
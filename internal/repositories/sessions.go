@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SessionRepository struct {
+	*internal.BaseRepository[models.Session, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	baseRepo := internal.NewBaseRepository[models.Session, uuid.UUID](db)
+	return &SessionRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+// GetUserSession returns the session only if it belongs to the user.
+func (r *SessionRepository) GetUserSession(userID, sessionID uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	if err := r.Scoped().Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListActive returns the user's sessions that can still be refreshed, most recently used first.
+func (r *SessionRepository) ListActive(userID uuid.UUID, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.Scoped().
+		Where("user_id = ? AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Touch records that the session was just used to refresh its tokens.
+func (r *SessionRepository) Touch(session *models.Session, ipAddress, userAgent string, expiresAt time.Time) error {
+	return r.Scoped().Model(session).Updates(map[string]interface{}{
+		"last_used_at": time.Now(),
+		"ip_address":   ipAddress,
+		"user_agent":   userAgent,
+		"expires_at":   expiresAt,
+	}).Error
+}
+
+// SetCompanyForUser moves the user's sessions to another tenant. Like UserRepository.SetCompany
+// it bypasses the tenant scope, so callers must authorize the membership change first.
+func (r *SessionRepository) SetCompanyForUser(userID uuid.UUID, companyID *uuid.UUID) error {
+	return r.db.Model(&models.Session{}).
+		Where("user_id = ?", userID).
+		Update("company_id", companyID).Error
+}
+
+func (r *SessionRepository) DeleteUserSessions(userID uuid.UUID) error {
+	return r.Scoped().Where("user_id = ?", userID).Delete(&models.Session{}).Error
+}
+
+// DeleteOtherUserSessions deletes every session of the user except the kept one.
+func (r *SessionRepository) DeleteOtherUserSessions(userID, keepSessionID uuid.UUID) error {
+	return r.Scoped().Where("user_id = ? AND id <> ?", userID, keepSessionID).Delete(&models.Session{}).Error
+}
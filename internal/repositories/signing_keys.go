@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository struct {
+	*internal.BaseRepository[models.SigningKey, string]
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) *SigningKeyRepository {
+	baseRepo := internal.NewBaseRepository[models.SigningKey, string](db)
+	return &SigningKeyRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+func (r *SigningKeyRepository) List() ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	if err := r.Scoped().Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SetPrivateKey replaces the stored private key, e.g. once it is encrypted.
+func (r *SigningKeyRepository) SetPrivateKey(key *models.SigningKey, privateKey string) error {
+	if err := r.Scoped().Model(key).Update("private_key", privateKey).Error; err != nil {
+		return err
+	}
+	key.PrivateKey = privateKey
+	return nil
+}
+
+// ListUsable returns the active key and retired keys that still verify tokens at the given time.
+func (r *SigningKeyRepository) ListUsable(now time.Time) ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	err := r.Scoped().
+		Where("status = ? OR expires_at > ?", models.SigningKeyStatusActive, now).
+		Order("created_at DESC").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Rotate retires the active key, keeping it for verification until verifyUntil, and
+// activates the new key. Keys that can no longer verify anything are removed.
+func (r *SigningKeyRepository) Rotate(newKey *models.SigningKey, verifyUntil time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Model(&models.SigningKey{}).
+			Where("status = ?", models.SigningKeyStatusActive).
+			Updates(map[string]interface{}{
+				"status":     models.SigningKeyStatusRetired,
+				"retired_at": now,
+				"expires_at": verifyUntil,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("status = ? AND expires_at <= ?", models.SigningKeyStatusRetired, now).
+			Delete(&models.SigningKey{}).Error
+		if err != nil {
+			return err
+		}
+
+		newKey.Status = models.SigningKeyStatusActive
+		return tx.Create(newKey).Error
+	})
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/testdb"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// tenantFixture holds two companies with a user each, and a user outside of any company.
+type tenantFixture struct {
+	companyA, companyB           models.Company
+	userA, userB, unassignedUser models.User
+}
+
+func newTenantFixture(t *testing.T, db *gorm.DB) *tenantFixture {
+	t.Helper()
+	f := &tenantFixture{
+		companyA: models.Company{Name: "Alpha Freight"},
+		companyB: models.Company{Name: "Beta Haulage"},
+	}
+	for _, company := range []*models.Company{&f.companyA, &f.companyB} {
+		if err := db.Create(company).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.userA = models.User{FirstName: "Ada", LastName: "Alpha", Email: "ada@alpha.example", CompanyID: &f.companyA.ID}
+	f.userB = models.User{FirstName: "Ben", LastName: "Beta", Email: "ben@beta.example", CompanyID: &f.companyB.ID}
+	f.unassignedUser = models.User{FirstName: "Una", LastName: "Assigned", Email: "una@example.com"}
+	for _, user := range []*models.User{&f.userA, &f.userB, &f.unassignedUser} {
+		if err := db.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func tenantDB(db *gorm.DB, companyID *uuid.UUID) *gorm.DB {
+	return db.WithContext(internal.WithTenant(context.Background(), companyID))
+}
+
+func TestTenantScopeHidesOtherCompanies(t *testing.T) {
+	db := testdb.Open(t)
+	f := newTenantFixture(t, db)
+	repo := NewUserRepository(tenantDB(db, &f.companyA.ID))
+
+	if user, err := repo.GetById(f.userA.ID); err != nil || user.ID != f.userA.ID {
+		t.Fatalf("own user: got %v (%v), want it found", user, err)
+	}
+	for _, other := range []models.User{f.userB, f.unassignedUser} {
+		if _, err := repo.GetById(other.ID); err == nil {
+			t.Fatalf("got %s of another tenant by ID", other.Email)
+		}
+		if _, err := repo.GetUserByEmail(other.Email); err == nil {
+			t.Fatalf("got %s of another tenant by email", other.Email)
+		}
+	}
+	if users, err := repo.GetUsersByCompany(f.companyB.ID); err != nil || len(users) != 0 {
+		t.Fatalf("got %d users of company B (%v), want none", len(users), err)
+	}
+	companies := NewCompanyRepository(tenantDB(db, &f.companyA.ID))
+	if _, err := companies.GetById(f.companyB.ID); err == nil {
+		t.Fatal("got company B from company A")
+	}
+}
+
+func TestTenantScopeGuardsWrites(t *testing.T) {
+	db := testdb.Open(t)
+	f := newTenantFixture(t, db)
+	repo := NewUserRepository(tenantDB(db, &f.companyA.ID))
+
+	if _, err := repo.Update(&f.userB, models.User{FirstName: "Mallory"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteById(f.userB.ID); err != nil {
+		t.Fatal(err)
+	}
+	var stored models.User
+	if err := db.First(&stored, "id = ?", f.userB.ID).Error; err != nil {
+		t.Fatalf("user of company B was deleted from company A: %v", err)
+	}
+	if stored.FirstName != "Ben" {
+		t.Fatalf("user of company B was renamed to %q from company A", stored.FirstName)
+	}
+}
+
+func TestTenantScopeWithoutCompany(t *testing.T) {
+	db := testdb.Open(t)
+	f := newTenantFixture(t, db)
+
+	// A nil company limits queries to rows outside of every company.
+	unassigned := NewUserRepository(tenantDB(db, nil))
+	if _, err := unassigned.GetById(f.unassignedUser.ID); err != nil {
+		t.Fatalf("user without company: got %v, want it found", err)
+	}
+	if _, err := unassigned.GetById(f.userA.ID); err == nil {
+		t.Fatal("got a user of company A without a company")
+	}
+
+	// Without a tenant in the context, e.g. in background jobs, nothing is filtered.
+	unscoped := NewUserRepository(db)
+	for _, user := range []models.User{f.userA, f.userB, f.unassignedUser} {
+		if _, err := unscoped.GetById(user.ID); err != nil {
+			t.Fatalf("unscoped lookup of %s: %v", user.Email, err)
+		}
+	}
+}
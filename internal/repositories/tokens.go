@@ -3,6 +3,7 @@ package repositories
 import (
 	"fleet-pulse-users-service/internal"
 	"fleet-pulse-users-service/internal/models"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -23,23 +24,76 @@ func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
 
 func (r *RefreshTokenRepository) GetByToken(token string) (*models.RefreshToken, error) {
 	var rt models.RefreshToken
-	if err := r.db.Where("token = ?", token).First(&rt).Error; err != nil {
+	if err := r.Scoped().Where("token = ?", token).First(&rt).Error; err != nil {
 		return nil, err
 	}
 	return &rt, nil
 }
 
 func (r *RefreshTokenRepository) Update(tokenObj *models.RefreshToken) (*models.RefreshToken, error) {
-	if err := r.db.Save(tokenObj).Error; err != nil {
+	if err := r.Scoped().Model(tokenObj).Select("*").Updates(tokenObj).Error; err != nil {
 		return nil, err
 	}
 	return tokenObj, nil
 }
 
+// Rotate consumes the current token and stores its successor in the same family.
+// It reports false, without storing anything, when the token was already consumed.
+func (r *RefreshTokenRepository) Rotate(current, next *models.RefreshToken) (bool, error) {
+	consumed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		scoped := func() *gorm.DB { return tx.Scopes(internal.TenantScope[models.RefreshToken]) }
+		now := time.Now()
+		result := scoped().Model(&models.RefreshToken{}).
+			Where("id = ? AND consumed_at IS NULL AND revoked_at IS NULL", current.ID).
+			Update("consumed_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		consumed = true
+
+		// Consumed tokens are only kept while a replay of them could still be accepted.
+		err := scoped().Where("family_id = ? AND consumed_at IS NOT NULL AND expires_at < ?", current.FamilyID, now).
+			Delete(&models.RefreshToken{}).Error
+		if err != nil {
+			return err
+		}
+
+		next.FamilyID = current.FamilyID
+		return tx.Create(next).Error
+	})
+	return consumed, err
+}
+
+// RevokeFamily revokes every token descending from the same login.
+func (r *RefreshTokenRepository) RevokeFamily(familyID uuid.UUID) error {
+	return r.Scoped().Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
 func (r *RefreshTokenRepository) Delete(tokenObj *models.RefreshToken) {
-	r.db.Delete(tokenObj)
+	r.Scoped().Delete(tokenObj)
 }
 
 func (r *RefreshTokenRepository) DeletePreviousTokens(userID uuid.UUID) {
-	r.db.Where("user_id = ?", userID).Delete(&models.RefreshToken{})
+	r.Scoped().Where("user_id = ?", userID).Delete(&models.RefreshToken{})
+}
+
+// DeleteOtherSessionTokens deletes the user's refresh tokens that do not belong to the kept session.
+func (r *RefreshTokenRepository) DeleteOtherSessionTokens(userID, keepSessionID uuid.UUID) error {
+	return r.Scoped().
+		Where("user_id = ? AND (session_id IS NULL OR session_id <> ?)", userID, keepSessionID).
+		Delete(&models.RefreshToken{}).Error
+}
+
+// SetCompanyForUser moves the user's tokens to another tenant. Like UserRepository.SetCompany
+// it bypasses the tenant scope, so callers must authorize the membership change first.
+func (r *RefreshTokenRepository) SetCompanyForUser(userID uuid.UUID, companyID *uuid.UUID) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ?", userID).
+		Update("company_id", companyID).Error
 }
@@ -23,8 +23,37 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 
 func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	var u models.User
-	if err := r.db.Where("email = ?", email).First(&u).Error; err != nil {
+	if err := r.Scoped().Where("email = ?", email).First(&u).Error; err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
+
+// EmailExists reports whether any user has the email. It bypasses the tenant scope because
+// emails are unique across companies.
+func (r *UserRepository) EmailExists(email string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *UserRepository) GetUsersByCompany(companyID uuid.UUID) ([]models.User, error) {
+	var users []models.User
+	if err := r.Scoped().Where("company_id = ?", companyID).Order("email").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// SetCompany moves the user to another company. It bypasses the tenant scope because the
+// user necessarily ends up outside of one of the tenants involved, so callers must
+// authorize the membership change first.
+func (r *UserRepository) SetCompany(user *models.User, companyID *uuid.UUID) (*models.User, error) {
+	if err := r.db.Model(user).Update("company_id", companyID).Error; err != nil {
+		return nil, err
+	}
+	user.CompanyID = companyID
+	return user, nil
+}
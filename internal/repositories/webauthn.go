@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebAuthnCredentialRepository struct {
+	*internal.BaseRepository[models.WebAuthnCredential, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewWebAuthnCredentialRepository(db *gorm.DB) *WebAuthnCredentialRepository {
+	baseRepo := internal.NewBaseRepository[models.WebAuthnCredential, uuid.UUID](db)
+	return &WebAuthnCredentialRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+func (r *WebAuthnCredentialRepository) ListByUser(userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := r.Scoped().Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error
+	return credentials, err
+}
+
+func (r *WebAuthnCredentialRepository) GetUserCredential(userID, id uuid.UUID) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	if err := r.Scoped().Where("id = ? AND user_id = ?", id, userID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *WebAuthnCredentialRepository) GetByCredentialID(credentialID []byte) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	if err := r.Scoped().Where("credential_id = ?", credentialID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// RecordLogin stores the signature counter and backup state reported by a sign-in.
+func (r *WebAuthnCredentialRepository) RecordLogin(
+	credential *models.WebAuthnCredential,
+	signCount int64,
+	backupState bool,
+	now time.Time,
+) error {
+	err := r.Scoped().Model(credential).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"backup_state": backupState,
+		"last_used_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	credential.SignCount = signCount
+	credential.BackupState = backupState
+	credential.LastUsedAt = &now
+	return nil
+}
+
+// FlagClone marks a passkey whose signature counter went backwards.
+func (r *WebAuthnCredentialRepository) FlagClone(credential *models.WebAuthnCredential) error {
+	if err := r.Scoped().Model(credential).Update("clone_warning", true).Error; err != nil {
+		return err
+	}
+	credential.CloneWarning = true
+	return nil
+}
+
+type WebAuthnSessionRepository struct {
+	*internal.BaseRepository[models.WebAuthnSession, uuid.UUID]
+	db *gorm.DB
+}
+
+func NewWebAuthnSessionRepository(db *gorm.DB) *WebAuthnSessionRepository {
+	baseRepo := internal.NewBaseRepository[models.WebAuthnSession, uuid.UUID](db)
+	return &WebAuthnSessionRepository{
+		BaseRepository: baseRepo,
+		db:             db,
+	}
+}
+
+// Consume deletes an unexpired session of the ceremony and returns it, so every challenge
+// is answered at most once.
+func (r *WebAuthnSessionRepository) Consume(id uuid.UUID, ceremony string, now time.Time) (*models.WebAuthnSession, error) {
+	var session models.WebAuthnSession
+	err := r.Scoped().Where("id = ? AND ceremony = ? AND expires_at > ?", id, ceremony, now).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	result := r.Scoped().Where("id = ?", session.ID).Delete(&models.WebAuthnSession{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &session, nil
+}
+
+// DeleteExpired removes sessions that can no longer be finished.
+func (r *WebAuthnSessionRepository) DeleteExpired(now time.Time) error {
+	return r.Scoped().Where("expires_at <= ?", now).Delete(&models.WebAuthnSession{}).Error
+}
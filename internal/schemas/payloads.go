@@ -1,15 +1,70 @@
 package schemas
 
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
 type CreateUserRequest struct {
 	FirstName string `json:"first_name" binding:"required"`
 	LastName  string `json:"last_name" binding:"required"`
 	Email     string `json:"email" binding:"required,email"`
-	Password  string `json:"password"`
+	Password  string `json:"password" binding:"required"`
+}
+
+// InviteUserRequest creates a company user that sets their own password through the emailed invite.
+type InviteUserRequest struct {
+	FirstName string `json:"first_name" binding:"required"`
+	LastName  string `json:"last_name" binding:"required"`
+	Email     string `json:"email" binding:"required,email"`
+	Role      string `json:"role" binding:"required" example:"driver"`
 }
 
 type LoginUserRequest struct {
-	Email    string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name" example:"Dispatcher desktop"`
+}
+
+type MFALoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	// Code is a TOTP code or a recovery code.
+	Code       string `json:"code" binding:"required" example:"123456"`
+	DeviceName string `json:"device_name" example:"Dispatcher desktop"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+// PasskeyLoginBeginRequest starts a passkey sign-in. Without an email the authenticator offers
+// the passkeys it keeps for this service.
+type PasskeyLoginBeginRequest struct {
+	Email      string `json:"email" example:"dispatcher@example.com"`
+	DeviceName string `json:"device_name" example:"Dispatcher desktop"`
+}
+
+// PasskeyLoginFinishRequest carries the PublicKeyCredential returned by navigator.credentials.get.
+type PasskeyLoginFinishRequest struct {
+	SessionID  uuid.UUID       `json:"session_id" binding:"required"`
+	Credential json.RawMessage `json:"credential" binding:"required" swaggertype:"object"`
+	DeviceName string          `json:"device_name" example:"Dispatcher desktop"`
+}
+
+// PasskeyRegistrationBeginRequest confirms the user before a passkey is added, since a passkey
+// signs in without the password.
+type PasskeyRegistrationBeginRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	// MFACode is required for users with two-factor authentication.
+	MFACode string `json:"mfa_code" example:"123456"`
+}
+
+// PasskeyRegistrationFinishRequest carries the PublicKeyCredential returned by navigator.credentials.create.
+type PasskeyRegistrationFinishRequest struct {
+	SessionID  uuid.UUID       `json:"session_id" binding:"required"`
+	Credential json.RawMessage `json:"credential" binding:"required" swaggertype:"object"`
+	Name       string          `json:"name" example:"Dispatch desk security key"`
 }
 
 type RefreshTokenRequest struct {
@@ -20,3 +75,67 @@ type AcceptInviteRequest struct {
 	Token    string `json:"token" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type CreateCompanyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type UpdateCompanyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type SetUserRolesRequest struct {
+	Roles []string `json:"roles" binding:"required"`
+}
+
+type IntrospectionRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" example:"access_token"`
+}
+
+type TokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required" example:"client_credentials"`
+	Scope        string `form:"scope" example:"telemetry:read"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+}
+
+// AuthorizationRequest holds the parameters of an OAuth authorization request (RFC 6749, RFC 7636).
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required" example:"code"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope" example:"trips:read vehicles:read"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge" binding:"required"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" binding:"required" example:"S256"`
+	// Nonce is copied into the ID token to bind it to the client's sign-in request.
+	Nonce string `form:"nonce" json:"nonce"`
+}
+
+// AuthorizeRequest signs the user in and answers the consent prompt. Approve may be left
+// out when the user already granted the requested scopes to the client.
+type AuthorizeRequest struct {
+	AuthorizationRequest
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	// MFACode is required for users with two-factor authentication.
+	MFACode string `json:"mfa_code" example:"123456"`
+	Approve *bool  `json:"approve"`
+}
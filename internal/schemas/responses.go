@@ -1,19 +1,199 @@
 package schemas
 
-import "github.com/google/uuid"
+import (
+	"fleet-pulse-users-service/internal/errors"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Email     string    `json:"email"`
+	ID        uuid.UUID  `json:"id"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Email     string     `json:"email"`
+	CompanyID *uuid.UUID `json:"company_id"`
+	// AcceptedAt is empty until the user accepts their invite.
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
 }
 
 type ErrorResponse struct {
 	Error string `json:"error" example:"user with such email already exists"`
 }
 
+// PasswordPolicyErrorResponse lists every password policy rule the submitted password breaks.
+type PasswordPolicyErrorResponse struct {
+	Error      string                     `json:"error" example:"password does not meet the password policy"`
+	Violations []errors.PasswordViolation `json:"violations"`
+}
+
+// OAuthErrorResponse is the error format of the OAuth endpoints (RFC 6749, section 5.2).
+type OAuthErrorResponse struct {
+	Error            string `json:"error" example:"invalid_client"`
+	ErrorDescription string `json:"error_description" example:"client authentication failed"`
+}
+
 type LoginResponse struct {
 	Token        string `json:"token" example:"eyJhbGciOiJI..."`
 	RefreshToken string `json:"refreshToken" example:"eyJhbGciOiJI"`
 }
+
+// MFAChallengeResponse is returned by the login of a user with two-factor authentication.
+// The challenge token is exchanged with a code at /v1/login/mfa.
+type MFAChallengeResponse struct {
+	MFARequired    bool      `json:"mfa_required" example:"true"`
+	ChallengeToken string    `json:"challenge_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+type MFAStatusResponse struct {
+	TOTPEnabled            bool  `json:"totp_enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// TOTPEnrollmentResponse holds the secret for the authenticator app, both as an otpauth:// URI
+// and as a QR code of that URI.
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/Fleet%20Pulse:driver@example.com?issuer=Fleet+Pulse&secret=JBSWY3DPEHPK3PXP"`
+	// QRCodePNG is the base64 encoded PNG image.
+	QRCodePNG []byte `json:"qr_code_png" swaggertype:"string" format:"base64"`
+}
+
+// RecoveryCodesResponse lists single use recovery codes. They are only ever shown once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"k7mqp-x2hra"`
+}
+
+// PasskeyCeremonyResponse holds the options for navigator.credentials.create or
+// navigator.credentials.get. The session ID is sent back together with the result.
+type PasskeyCeremonyResponse struct {
+	SessionID uuid.UUID   `json:"session_id"`
+	Options   interface{} `json:"options" swaggertype:"object"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+type PasskeyResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	Name           string     `json:"name" example:"Dispatch desk security key"`
+	Transports     []string   `json:"transports" example:"usb,nfc"`
+	BackupEligible bool       `json:"backup_eligible"`
+	BackupState    bool       `json:"backup_state"`
+	CloneWarning   bool       `json:"clone_warning"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
+
+type CompanyResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RoleResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name" example:"dispatcher"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions,omitempty" example:"trips:read,trips:write"`
+}
+
+type JWK struct {
+	Kty string `json:"kty" example:"RSA"`
+	Use string `json:"use" example:"sig"`
+	Alg string `json:"alg" example:"RS256"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e" example:"AQAB"`
+}
+
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
+
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	DeviceName string    `json:"device_name" example:"Driver tablet"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	ClientID   *string   `json:"client_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+type InviteResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Email       string     `json:"email" example:"driver@example.com"`
+	CompanyID   *uuid.UUID `json:"company_id,omitempty"`
+	InvitedByID *uuid.UUID `json:"invited_by_id,omitempty"`
+	Status      string     `json:"status" example:"pending"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// IntrospectionResponse follows RFC 7662. Inactive tokens carry no other member.
+type IntrospectionResponse struct {
+	Active    bool       `json:"active"`
+	Scope     string     `json:"scope,omitempty" example:"trips:read trips:write"`
+	ClientID  string     `json:"client_id,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	TokenType string     `json:"token_type,omitempty" example:"access_token"`
+	Exp       int64      `json:"exp,omitempty"`
+	Iat       int64      `json:"iat,omitempty"`
+	Sub       string     `json:"sub,omitempty"`
+	Jti       string     `json:"jti,omitempty"`
+	CompanyID *uuid.UUID `json:"company_id,omitempty"`
+}
+
+// TokenResponse is the successful response of the OAuth token endpoint (RFC 6749).
+type TokenResponse struct {
+	AccessToken  string `json:"access_token" example:"eyJhbGciOiJSUzI1NiIs..."`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in" example:"900"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty" example:"telemetry:read"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+type AuthorizationInfoResponse struct {
+	ClientID    string   `json:"client_id"`
+	ClientName  string   `json:"client_name" example:"Driver app"`
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes" example:"trips:read,vehicles:read"`
+}
+
+type AuthorizationResponse struct {
+	RedirectTo string `json:"redirect_to" example:"fleetpulse://callback?code=SplxlOBeZQQYbYS6WxSbIA&state=xyz"`
+}
+
+// UserInfoResponse holds the standard OpenID Connect claims about the signed in user.
+type UserInfoResponse struct {
+	Sub        string `json:"sub"`
+	Name       string `json:"name,omitempty" example:"Jane Doe"`
+	GivenName  string `json:"given_name,omitempty" example:"Jane"`
+	FamilyName string `json:"family_name,omitempty" example:"Doe"`
+	Email      string `json:"email,omitempty"`
+	UpdatedAt  int64  `json:"updated_at,omitempty"`
+}
+
+// OpenIDConfiguration is the OpenID Connect discovery document.
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
@@ -7,59 +7,163 @@ import (
 	"fleet-pulse-users-service/internal/config"
 	"fleet-pulse-users-service/internal/errors"
 	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/passwords"
 	"fleet-pulse-users-service/internal/repositories"
 	"fleet-pulse-users-service/internal/schemas"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-var jwtSecret = []byte(config.Get().Auth.JwtSecret)
-
+// Claims are carried by access tokens. Besides identifying the user, or the client for
+// machine callers, they hold everything downstream services need to authorize a request
+// without calling back into this service.
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID      string     `json:"user_id,omitempty"`
+	Email       string     `json:"email"`
+	CompanyID   *uuid.UUID `json:"company_id,omitempty"`
+	Roles       []string   `json:"roles,omitempty"`
+	Permissions []string   `json:"permissions,omitempty"`
+	SessionID   *uuid.UUID `json:"sid,omitempty"`
+	ClientID    string     `json:"client_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ClientInfo describes the device a session is opened from.
+type ClientInfo struct {
+	DeviceName string
+	UserAgent  string
+	IPAddress  string
+}
+
 type AuthService struct {
 	refreshTokenRepository *repositories.RefreshTokenRepository
 	userRepository         *repositories.UserRepository
+	roleRepository         *repositories.RoleRepository
+	sessionRepository      *repositories.SessionRepository
+	revokedTokenRepository *repositories.RevokedAccessTokenRepository
+	securityEvents         *SecurityEventService
+	mfa                    *MFAService
+	passkeys               *WebAuthnService
 }
 
 func NewAuthService(db *gorm.DB) *AuthService {
 	userRepo := repositories.NewUserRepository(db)
 	refreshTokenRepository := repositories.NewRefreshTokenRepository(db)
-	return &AuthService{refreshTokenRepository: refreshTokenRepository, userRepository: userRepo}
+	roleRepository := repositories.NewRoleRepository(db)
+	return &AuthService{
+		refreshTokenRepository: refreshTokenRepository,
+		userRepository:         userRepo,
+		roleRepository:         roleRepository,
+		sessionRepository:      repositories.NewSessionRepository(db),
+		revokedTokenRepository: repositories.NewRevokedAccessTokenRepository(db),
+		securityEvents:         NewSecurityEventService(db),
+		mfa:                    NewMFAService(db),
+		passkeys:               NewWebAuthnService(db),
+	}
 }
 
-func (s AuthService) GenerateJWT(userID string, duration time.Duration) (string, error) {
-	claims := &Claims{
-		UserID: userID,
+// UserClaims builds access token claims from the user's current company and roles.
+func (s AuthService) UserClaims(user *models.User) (*Claims, error) {
+	roles, err := s.roleRepository.GetUserRoles(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	roleNames := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleNames = append(roleNames, role.Name)
+	}
 
+	permissions, err := s.roleRepository.GetUserPermissions(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Claims{
+		UserID:      user.ID.String(),
+		Email:       user.Email,
+		CompanyID:   user.CompanyID,
+		Roles:       roleNames,
+		Permissions: permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Subject: user.ID.String(),
 		},
+	}, nil
+}
+
+func (s AuthService) GenerateJWT(claims *Claims, duration time.Duration) (string, error) {
+	claims.Issuer = config.Get().Auth.Issuer
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(duration))
+	claims.IssuedAt = jwt.NewNumericDate(time.Now())
+	// The jti lets a single token be revoked before it expires.
+	claims.ID = uuid.NewString()
+	return signJWT(claims)
+}
+
+// signJWT signs the claims with the active key and names the key in the kid header.
+func signJWT(claims jwt.Claims) (string, error) {
+	key := SigningKeys().Active()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.PrivateKey)
+}
+
+// generateUserJWT issues an access token for the user's session with the configured lifetime.
+// Tokens of sessions opened through an OAuth client only carry the permissions granted to it.
+func (s AuthService) generateUserJWT(user *models.User, session *models.Session) (string, error) {
+	claims, err := s.UserClaims(user)
+	if err != nil {
+		return "", err
+	}
+	if session != nil {
+		claims.SessionID = &session.ID
+		if session.ClientID != nil {
+			claims.ClientID = *session.ClientID
+			claims.Permissions = intersectStrings(claims.Permissions, session.ScopeList())
+		}
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return s.GenerateJWT(claims, time.Duration(config.Get().Auth.JwtAccessTokenExpireInMinutes)*time.Minute)
 }
 
 func (s AuthService) ParseJWT(tokenStr string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+		kid, _ := t.Header["kid"].(string)
+		key, ok := SigningKeys().Lookup(kid)
+		if !ok {
+			return nil, errors.ErrInvalidToken
+		}
+		return key.Public(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
 	if err != nil || !token.Valid {
 		return nil, errors.ErrInvalidToken
 	}
 	return claims, nil
 }
 
+// ValidateAccessToken parses the access token and rejects it if it has been revoked.
+func (s AuthService) ValidateAccessToken(tokenStr string) (*Claims, error) {
+	claims, err := s.ParseJWT(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if TokenRevocations().IsRevoked(claims) {
+		return nil, errors.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// RevokeAccessToken rejects the access token from now on, before it expires.
+func (s AuthService) RevokeAccessToken(claims *Claims) error {
+	return TokenRevocations().RevokeToken(s.revokedTokenRepository, claims)
+}
+
 func (s AuthService) GenerateRefreshToken() (string, error) {
 	b := make([]byte, 256)
 	_, err := rand.Read(b)
@@ -70,33 +174,151 @@ func (s AuthService) GenerateRefreshToken() (string, error) {
 }
 
 func HashPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hashed), nil
+	return passwords.Hashers().Hash(password)
 }
 
 func CheckPassword(hashedPassword, password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	return err == nil
+	ok, _ := passwords.Hashers().Verify(hashedPassword, password)
+	return ok
 }
 
-func (s AuthService) LoginUser(loginPayload schemas.LoginUserRequest) (string, string, error) {
-	if loginPayload.Email == "" || loginPayload.Password == "" {
-		return "", "", errors.ErrInvalidCredentials
+// Authenticate checks the user's credentials.
+func (s AuthService) Authenticate(email, password string) (*models.User, error) {
+	if email == "" || password == "" {
+		return nil, errors.ErrInvalidCredentials
 	}
-	userObj, err := s.userRepository.GetUserByEmail(loginPayload.Email)
-	settings := config.Get()
+	userObj, err := s.userRepository.GetUserByEmail(email)
 
 	if err != nil || userObj == nil {
-		return "", "", errors.ErrUserNotFound
+		return nil, errors.ErrUserNotFound
+	}
+	ok, needsRehash := passwords.Hashers().Verify(userObj.Password, password)
+	if !ok {
+		return nil, errors.ErrInvalidCredentials
+	}
+	if needsRehash {
+		s.rehashPassword(userObj, password)
+	}
+	return userObj, nil
+}
+
+// rehashPassword replaces an outdated hash, e.g. a legacy bcrypt one, while the plain password
+// is at hand. Failing to do so never fails the sign-in, the next one tries again.
+func (s AuthService) rehashPassword(userObj *models.User, password string) {
+	hashed, err := HashPassword(password)
+	if err == nil {
+		_, err = s.userRepository.Update(userObj, models.User{Password: hashed})
+	}
+	if err != nil {
+		log.Printf("Failed to upgrade password hash of user %s: %v", userObj.ID, err)
+	}
+}
+
+// LoginUser signs the user in with their password. Users with two-factor authentication get
+// a challenge instead of tokens, which LoginWithMFA exchanges together with a code.
+func (s AuthService) LoginUser(
+	loginPayload schemas.LoginUserRequest,
+	client ClientInfo,
+) (accessToken string, refreshToken string, challenge *MFAChallengeToken, err error) {
+	userObj, err := s.Authenticate(loginPayload.Email, loginPayload.Password)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	mfaEnabled, err := s.mfa.IsEnabled(userObj.ID)
+	if err != nil {
+		return "", "", nil, err
 	}
-	if !CheckPassword(userObj.Password, loginPayload.Password) {
-		return "", "", errors.ErrInvalidCredentials
+	if mfaEnabled {
+		challenge, err = s.mfa.CreateChallenge(userObj, client.DeviceName)
+		return "", "", challenge, err
 	}
 
-	accessToken, err := s.GenerateJWT(userObj.ID.String(), time.Duration(settings.Auth.JwtAccessTokenExpireInMinutes)*time.Minute)
+	accessToken, refreshToken, err = s.startSession(userObj, newSession(client))
+	return accessToken, refreshToken, nil, err
+}
+
+// LoginWithMFA completes a sign-in started by LoginUser with a TOTP or recovery code.
+func (s AuthService) LoginWithMFA(challengeToken, code string, client ClientInfo) (string, string, error) {
+	challenge, err := s.mfa.CompleteChallenge(challengeToken, code)
+	if err != nil {
+		return "", "", err
+	}
+	userObj, err := s.userRepository.GetById(challenge.UserID)
+	if err != nil || userObj == nil {
+		return "", "", errors.ErrInvalidMFAChallenge
+	}
+	if client.DeviceName == "" {
+		client.DeviceName = challenge.DeviceName
+	}
+	return s.startSession(userObj, newSession(client))
+}
+
+// BeginPasskeyLogin starts a sign-in with a passkey instead of a password. The email is
+// optional, without it the authenticator offers the passkeys it keeps for this service.
+func (s AuthService) BeginPasskeyLogin(email, deviceName string) (*PasskeyCeremony, error) {
+	return s.passkeys.BeginLogin(strings.TrimSpace(email), deviceName)
+}
+
+// LoginWithPasskey completes a sign-in started by BeginPasskeyLogin. The authenticator has
+// verified the user with a fingerprint or PIN, so no TOTP code is asked for on top.
+func (s AuthService) LoginWithPasskey(sessionID uuid.UUID, response []byte, client ClientInfo) (string, string, error) {
+	userObj, deviceName, err := s.passkeys.FinishLogin(sessionID, response)
+	if err != nil {
+		return "", "", err
+	}
+	if client.DeviceName == "" {
+		client.DeviceName = deviceName
+	}
+	return s.startSession(userObj, newSession(client))
+}
+
+// StartClientSession opens a session for the user in an OAuth client, limited to the granted scopes.
+func (s AuthService) StartClientSession(
+	userObj *models.User,
+	oauthClient *models.Client,
+	scopes []string,
+	client ClientInfo,
+) (accessToken string, refreshToken string, session *models.Session, err error) {
+	session = newSession(client)
+	if session.DeviceName == "" {
+		session.DeviceName = oauthClient.Name
+	}
+	session.ClientID = &oauthClient.ClientID
+	session.Scopes = strings.Join(scopes, " ")
+
+	accessToken, refreshToken, err = s.startSession(userObj, session)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return accessToken, refreshToken, session, nil
+}
+
+func newSession(client ClientInfo) *models.Session {
+	return &models.Session{
+		DeviceName: client.DeviceName,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+	}
+}
+
+// startSession opens the session for the user and issues its first token pair.
+// Sessions on other devices are left untouched.
+func (s AuthService) startSession(userObj *models.User, session *models.Session) (string, string, error) {
+	settings := config.Get()
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(settings.Auth.JwtRefreshTokenExpireInHours) * time.Hour)
+
+	session.UserID = userObj.ID
+	session.CompanyID = userObj.CompanyID
+	session.LastUsedAt = now
+	session.ExpiresAt = expiresAt
+	session, err := s.sessionRepository.Create(session)
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := s.generateUserJWT(userObj, session)
 	if err != nil {
 		return "", "", err
 	}
@@ -105,11 +327,13 @@ func (s AuthService) LoginUser(loginPayload schemas.LoginUserRequest) (string, s
 	if err != nil {
 		return "", "", err
 	}
-	s.refreshTokenRepository.DeletePreviousTokens(userObj.ID)
 	_, err = s.refreshTokenRepository.Create(&models.RefreshToken{
 		UserID:    userObj.ID,
+		CompanyID: userObj.CompanyID,
+		FamilyID:  uuid.New(),
+		SessionID: &session.ID,
 		Token:     HashRefreshToken(refreshToken),
-		ExpiresAt: time.Now().Add(time.Duration(settings.Auth.JwtRefreshTokenExpireInHours) * time.Hour),
+		ExpiresAt: expiresAt,
 	})
 	if err != nil {
 		return "", "", err
@@ -118,44 +342,164 @@ func (s AuthService) LoginUser(loginPayload schemas.LoginUserRequest) (string, s
 	return accessToken, refreshToken, nil
 }
 
-func (s AuthService) RefreshAccessToken(rawRefreshToken string) (newAccessToken string, newRefreshToken string, err error) {
+// RefreshAccessToken exchanges a refresh token for a new access and refresh token pair.
+// The presented token is consumed; presenting it again revokes its whole family.
+func (s AuthService) RefreshAccessToken(
+	rawRefreshToken string,
+	client ClientInfo,
+) (newAccessToken string, newRefreshToken string, err error) {
+	newAccessToken, newRefreshToken, _, err = s.refreshTokens(rawRefreshToken, client, nil)
+	return newAccessToken, newRefreshToken, err
+}
+
+// RefreshClientSession is RefreshAccessToken for OAuth clients. The refresh token must have
+// been issued to the given client.
+func (s AuthService) RefreshClientSession(
+	rawRefreshToken string,
+	oauthClientID string,
+	client ClientInfo,
+) (newAccessToken string, newRefreshToken string, session *models.Session, err error) {
+	return s.refreshTokens(rawRefreshToken, client, &oauthClientID)
+}
+
+func (s AuthService) refreshTokens(
+	rawRefreshToken string,
+	client ClientInfo,
+	oauthClientID *string,
+) (newAccessToken string, newRefreshToken string, session *models.Session, err error) {
 	// Hash the incoming refresh token
 	hashedToken := HashRefreshToken(rawRefreshToken)
 	settings := config.Get()
 
 	tokenObj, err := s.refreshTokenRepository.GetByToken(hashedToken)
 	if err != nil || tokenObj == nil {
-		return "", "", errors.ErrInvalidToken
+		return "", "", nil, errors.ErrInvalidToken
+	}
+	if tokenObj.RevokedAt != nil {
+		return "", "", nil, errors.ErrInvalidToken
+	}
+	if tokenObj.ConsumedAt != nil {
+		return "", "", nil, s.handleRefreshTokenReuse(tokenObj)
 	}
 
 	if time.Now().After(tokenObj.ExpiresAt) {
 		s.refreshTokenRepository.Delete(tokenObj)
-		return "", "", errors.ErrExpiredToken
+		return "", "", nil, errors.ErrExpiredToken
 	}
 
-	newAccessToken, err = s.GenerateJWT(
-		tokenObj.UserID.String(),
-		time.Duration(settings.Auth.JwtAccessTokenExpireInMinutes)*time.Minute,
-	)
+	if tokenObj.SessionID != nil {
+		session, err = s.sessionRepository.GetById(*tokenObj.SessionID)
+		if err != nil || session == nil {
+			return "", "", nil, errors.ErrInvalidToken
+		}
+	}
+	if oauthClientID != nil && (session == nil || session.ClientID == nil || *session.ClientID != *oauthClientID) {
+		return "", "", nil, errors.ErrInvalidToken
+	}
+
+	userObj, err := s.userRepository.GetById(tokenObj.UserID)
+	if err != nil || userObj == nil {
+		return "", "", nil, errors.ErrInvalidToken
+	}
+
+	// Claims are rebuilt so that company and role changes reach the new access token.
+	newAccessToken, err = s.generateUserJWT(userObj, session)
 	if err != nil {
-		return "", "", err
+		return "", "", nil, err
 	}
 
 	newRefreshTokenRaw, err := s.GenerateRefreshToken()
 	if err != nil {
-		return "", "", err
+		return "", "", nil, err
 	}
 
-	tokenObj.Token = HashRefreshToken(newRefreshTokenRaw)
-	tokenObj.ExpiresAt = time.Now().Add(time.Duration(settings.Auth.JwtRefreshTokenExpireInHours) * time.Hour)
-	if _, err := s.refreshTokenRepository.Update(tokenObj); err != nil {
-		return "", "", err
+	expiresAt := time.Now().Add(time.Duration(settings.Auth.JwtRefreshTokenExpireInHours) * time.Hour)
+	consumed, err := s.refreshTokenRepository.Rotate(tokenObj, &models.RefreshToken{
+		UserID:    userObj.ID,
+		CompanyID: userObj.CompanyID,
+		SessionID: tokenObj.SessionID,
+		Token:     HashRefreshToken(newRefreshTokenRaw),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+	if !consumed {
+		// Someone else consumed the token between the lookup and the rotation.
+		return "", "", nil, s.handleRefreshTokenReuse(tokenObj)
+	}
+
+	if session != nil {
+		if err := s.sessionRepository.Touch(session, client.IPAddress, client.UserAgent, expiresAt); err != nil {
+			return "", "", nil, err
+		}
 	}
 
-	return newAccessToken, newRefreshTokenRaw, nil
+	return newAccessToken, newRefreshTokenRaw, session, nil
+}
+
+// handleRefreshTokenReuse revokes the family of a replayed refresh token. Either the
+// legitimate client or an attacker holds a stolen copy, so neither may continue.
+func (s AuthService) handleRefreshTokenReuse(tokenObj *models.RefreshToken) error {
+	if err := s.refreshTokenRepository.RevokeFamily(tokenObj.FamilyID); err != nil {
+		return err
+	}
+	if tokenObj.SessionID != nil {
+		if err := s.sessionRepository.DeleteById(*tokenObj.SessionID); err != nil {
+			return err
+		}
+	}
+	s.securityEvents.Record(models.SecurityEventRefreshTokenReuse, tokenObj.UserID, tokenObj.CompanyID, map[string]interface{}{
+		"family_id":        tokenObj.FamilyID.String(),
+		"refresh_token_id": tokenObj.ID.String(),
+	})
+	return errors.ErrRefreshTokenReused
 }
 
 func HashRefreshToken(token string) string {
 	h := sha256.Sum256([]byte(token))
 	return fmt.Sprintf("%x", h)
 }
+
+// Logout ends the session the refresh token belongs to. The access token, when given and
+// issued to the same user, is revoked too so that it cannot be used until it expires.
+func (s AuthService) Logout(rawRefreshToken, accessToken string) error {
+	tokenObj, err := s.refreshTokenRepository.GetByToken(HashRefreshToken(rawRefreshToken))
+	if err != nil || tokenObj == nil || tokenObj.RevokedAt != nil {
+		return errors.ErrInvalidToken
+	}
+
+	if accessToken != "" {
+		claims, err := s.ValidateAccessToken(accessToken)
+		if err == nil && claims.UserID == tokenObj.UserID.String() {
+			if err = s.RevokeAccessToken(claims); err != nil {
+				return err
+			}
+		}
+	}
+
+	if tokenObj.SessionID != nil {
+		return s.sessionRepository.DeleteById(*tokenObj.SessionID)
+	}
+	return s.refreshTokenRepository.RevokeFamily(tokenObj.FamilyID)
+}
+
+// LogoutAll ends every session of the user and rejects the access tokens already issued.
+func (s AuthService) LogoutAll(userID uuid.UUID) error {
+	if err := s.sessionRepository.DeleteUserSessions(userID); err != nil {
+		return err
+	}
+	s.refreshTokenRepository.DeletePreviousTokens(userID)
+	return TokenRevocations().RevokeUserTokens(s.revokedTokenRepository, userID)
+}
+
+// intersectStrings returns the values present in both slices, in the order of the first.
+func intersectStrings(values, allowed []string) []string {
+	intersection := make([]string, 0, len(values))
+	for _, value := range values {
+		if slices.Contains(allowed, value) {
+			intersection = append(intersection, value)
+		}
+	}
+	return intersection
+}
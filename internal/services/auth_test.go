@@ -0,0 +1,196 @@
+package services
+
+import (
+	stderrors "errors"
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/testdb"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const testPassword = "Correct-Horse-Battery-9"
+
+// testKeyEncryptionKey encrypts signing keys in tests, "0123456789abcdef" twice.
+const testKeyEncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func createUser(t *testing.T, db *gorm.DB) *models.User {
+	t.Helper()
+	password, err := HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{FirstName: "Pat", LastName: "Planner", Email: "pat@example.com", Password: password}
+	if err = db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// newTokenAuthService loads signing keys and token revocations backed by db, like main does.
+func newTokenAuthService(t *testing.T, db *gorm.DB) *AuthService {
+	t.Helper()
+	config.Get().Auth.JwtKeyEncryptionKey = testKeyEncryptionKey
+	if _, err := InitSigningKeys(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InitTokenRevocations(db); err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthService(db)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+
+	_, refreshToken, _, err := authService.LoginUser(schemas.LoginUserRequest{Email: user.Email, Password: testPassword}, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, rotatedToken, err := authService.RefreshAccessToken(refreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotatedToken == refreshToken {
+		t.Fatal("the refresh token was not rotated")
+	}
+	if _, err = authService.ValidateAccessToken(accessToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = authService.RefreshAccessToken(rotatedToken, ClientInfo{}); err != nil {
+		t.Fatalf("rotated token: got %v, want it accepted", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+
+	_, stolenToken, _, err := authService.LoginUser(schemas.LoginUserRequest{Email: user.Email, Password: testPassword}, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, legitimateToken, err := authService.RefreshAccessToken(stolenToken, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = authService.RefreshAccessToken(stolenToken, ClientInfo{}); err != errors.ErrRefreshTokenReused {
+		t.Fatalf("replayed token: got %v, want ErrRefreshTokenReused", err)
+	}
+	// Neither side may go on, the legitimate client cannot be told from the attacker.
+	if _, _, err = authService.RefreshAccessToken(legitimateToken, ClientInfo{}); err != errors.ErrInvalidToken {
+		t.Fatalf("newest token of the family: got %v, want ErrInvalidToken", err)
+	}
+
+	var sessions, events int64
+	db.Model(&models.Session{}).Where("user_id = ?", user.ID).Count(&sessions)
+	if sessions != 0 {
+		t.Fatalf("got %d sessions, want the session of the family ended", sessions)
+	}
+	db.Model(&models.SecurityEvent{}).Where("type = ?", models.SecurityEventRefreshTokenReuse).Count(&events)
+	if events != 1 {
+		t.Fatalf("got %d reuse events, want 1", events)
+	}
+}
+
+func TestRefreshTokenReuseLeavesOtherFamilies(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	login := schemas.LoginUserRequest{Email: user.Email, Password: testPassword}
+
+	_, stolenToken, _, err := authService.LoginUser(login, ClientInfo{DeviceName: "Laptop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherDeviceToken, _, err := authService.LoginUser(login, ClientInfo{DeviceName: "Phone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = authService.RefreshAccessToken(stolenToken, ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = authService.RefreshAccessToken(stolenToken, ClientInfo{}); err != errors.ErrRefreshTokenReused {
+		t.Fatalf("replayed token: got %v, want ErrRefreshTokenReused", err)
+	}
+
+	if _, _, err = authService.RefreshAccessToken(otherDeviceToken, ClientInfo{}); err != nil {
+		t.Fatalf("token of another sign-in: got %v, want it accepted", err)
+	}
+}
+
+func TestRefreshTokenRotatesOnlyOnce(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+
+	_, refreshToken, _, err := authService.LoginUser(schemas.LoginUserRequest{Email: user.Email, Password: testPassword}, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two refreshes racing each other both read the token before either consumes it.
+	repo := repositories.NewRefreshTokenRepository(db)
+	current, err := repo.GetByToken(HashRefreshToken(refreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false} {
+		next := &models.RefreshToken{
+			UserID:    user.ID,
+			SessionID: current.SessionID,
+			Token:     HashRefreshToken(fmt.Sprintf("successor-%d", i)),
+			ExpiresAt: current.ExpiresAt,
+		}
+		consumed, err := repo.Rotate(current, next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if consumed != want {
+			t.Fatalf("rotation %d: got consumed %v, want %v", i+1, consumed, want)
+		}
+	}
+}
+
+func TestAuthenticateUpgradesBcryptHashes(t *testing.T) {
+	db := testdb.Open(t)
+	authService := NewAuthService(db)
+	user := createUser(t, db)
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Model(user).Update("password", string(bcryptHash)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = authService.Authenticate(user.Email, "wrong"); !stderrors.Is(err, errors.ErrInvalidCredentials) {
+		t.Fatalf("a wrong password got %v, want ErrInvalidCredentials", err)
+	}
+	var stored models.User
+	db.First(&stored, "id = ?", user.ID)
+	if stored.Password != string(bcryptHash) {
+		t.Fatal("a failed sign-in replaced the hash")
+	}
+
+	if _, err = authService.Authenticate(user.Email, testPassword); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&stored, "id = ?", user.ID)
+	if !strings.HasPrefix(stored.Password, "$argon2id$") {
+		t.Fatalf("the hash was not upgraded to argon2id: %q", stored.Password)
+	}
+	if _, err = authService.Authenticate(user.Email, testPassword); err != nil {
+		t.Fatalf("the upgraded hash does not verify: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"fleet-pulse-users-service/internal/schemas"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CompanyService struct {
+	repo                   *repositories.CompanyRepository
+	userRepo               *repositories.UserRepository
+	roleRepo               *repositories.RoleRepository
+	sessionRepo            *repositories.SessionRepository
+	refreshTokenRepository *repositories.RefreshTokenRepository
+	revokedTokenRepo       *repositories.RevokedAccessTokenRepository
+}
+
+func NewCompanyService(db *gorm.DB) *CompanyService {
+	return &CompanyService{
+		repo:                   repositories.NewCompanyRepository(db),
+		userRepo:               repositories.NewUserRepository(db),
+		roleRepo:               repositories.NewRoleRepository(db),
+		sessionRepo:            repositories.NewSessionRepository(db),
+		refreshTokenRepository: repositories.NewRefreshTokenRepository(db),
+		revokedTokenRepo:       repositories.NewRevokedAccessTokenRepository(db),
+	}
+}
+
+func (s *CompanyService) GetCompanyById(id uuid.UUID) (*models.Company, error) {
+	company, err := s.repo.GetById(id)
+	if err != nil || company == nil {
+		return nil, errors.ErrCompanyNotFound
+	}
+	return company, nil
+}
+
+func (s *CompanyService) ListCompanies() ([]models.Company, error) {
+	return s.repo.List()
+}
+
+// CreateCompany creates a new company and makes the creator its first member and fleet admin.
+func (s *CompanyService) CreateCompany(creatorID uuid.UUID, data schemas.CreateCompanyRequest) (*models.Company, error) {
+	creator, err := s.userRepo.GetById(creatorID)
+	if err != nil || creator == nil {
+		return nil, errors.ErrUserNotFound
+	}
+	if creator.CompanyID != nil {
+		return nil, errors.ErrAlreadyCompanyMember
+	}
+
+	company, err := s.repo.Create(&models.Company{Name: data.Name})
+	if err != nil {
+		return nil, err
+	}
+	if err = s.moveUser(creator, &company.ID); err != nil {
+		return nil, err
+	}
+
+	adminRoles, err := s.roleRepo.GetByNames([]string{models.RoleFleetAdmin})
+	if err != nil {
+		return nil, err
+	}
+	if err = s.roleRepo.SetUserRoles(creator, adminRoles); err != nil {
+		return nil, err
+	}
+	return company, nil
+}
+
+func (s *CompanyService) UpdateCompany(id uuid.UUID, data schemas.UpdateCompanyRequest) (*models.Company, error) {
+	company, err := s.GetCompanyById(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Update(company, models.Company{Name: data.Name})
+}
+
+// DeleteCompany deletes the company after detaching its members, who keep their accounts
+// but lose their roles and are signed out everywhere.
+func (s *CompanyService) DeleteCompany(id uuid.UUID) error {
+	company, err := s.GetCompanyById(id)
+	if err != nil {
+		return err
+	}
+	members, err := s.userRepo.GetUsersByCompany(company.ID)
+	if err != nil {
+		return err
+	}
+	for i := range members {
+		if err = s.detachMember(&members[i]); err != nil {
+			return err
+		}
+	}
+	return s.repo.DeleteObj(company)
+}
+
+func (s *CompanyService) ListMembers(companyID uuid.UUID) ([]models.User, error) {
+	if _, err := s.GetCompanyById(companyID); err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetUsersByCompany(companyID)
+}
+
+func (s *CompanyService) RemoveMember(companyID, userID uuid.UUID) error {
+	if _, err := s.GetCompanyById(companyID); err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetById(userID)
+	if err != nil || user == nil {
+		return errors.ErrUserNotFound
+	}
+	if user.CompanyID == nil || *user.CompanyID != companyID {
+		return errors.ErrNotCompanyMember
+	}
+
+	return s.detachMember(user)
+}
+
+// detachMember takes the user out of their company. A former member must not keep acting on
+// behalf of the company, so their roles go and every session and token is revoked.
+func (s *CompanyService) detachMember(user *models.User) error {
+	if err := s.sessionRepo.DeleteUserSessions(user.ID); err != nil {
+		return err
+	}
+	s.refreshTokenRepository.DeletePreviousTokens(user.ID)
+	if err := TokenRevocations().RevokeUserTokens(s.revokedTokenRepo, user.ID); err != nil {
+		return err
+	}
+	if err := s.roleRepo.SetUserRoles(user, []models.Role{}); err != nil {
+		return err
+	}
+	_, err := s.userRepo.SetCompany(user, nil)
+	return err
+}
+
+func (s *CompanyService) moveUser(user *models.User, companyID *uuid.UUID) error {
+	if _, err := s.userRepo.SetCompany(user, companyID); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.SetCompanyForUser(user.ID, companyID); err != nil {
+		return err
+	}
+	return s.refreshTokenRepository.SetCompanyForUser(user.ID, companyID)
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/testdb"
+	"testing"
+)
+
+func TestDeleteCompanySignsOutMembers(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	role := &models.Role{
+		Name:        models.RoleFleetAdmin,
+		Description: "Fleet admin",
+		Permissions: []models.Permission{{Name: "users:write", Description: "Manage users"}},
+	}
+	if err := db.Create(role).Error; err != nil {
+		t.Fatal(err)
+	}
+	companies := NewCompanyService(db)
+	company, err := companies.CreateCompany(user.ID, schemas.CreateCompanyRequest{Name: "Alpha Freight"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, refreshToken := signIn(t, authService, user.Email)
+
+	if err = companies.DeleteCompany(company.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = authService.RefreshAccessToken(refreshToken, ClientInfo{}); err == nil {
+		t.Fatal("a former member refreshed their token after the company was deleted")
+	}
+
+	var roles int64
+	db.Table("user_roles").Where("user_id = ?", user.ID).Count(&roles)
+	if roles != 0 {
+		t.Errorf("the former member kept %d roles", roles)
+	}
+	var sessions int64
+	db.Model(&models.Session{}).Where("user_id = ?", user.ID).Count(&sessions)
+	if sessions != 0 {
+		t.Errorf("the former member kept %d sessions", sessions)
+	}
+	var stored models.User
+	db.First(&stored, "id = ?", user.ID)
+	if stored.CompanyID != nil {
+		t.Errorf("the former member still points at company %s", stored.CompanyID)
+	}
+}
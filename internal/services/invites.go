@@ -0,0 +1,266 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/mailer"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"fleet-pulse-users-service/internal/schemas"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var inviteSecret = []byte(config.Get().Auth.InviteSecret)
+
+type InviteClaims struct {
+	InviteID uuid.UUID `json:"invite_id"`
+	// Nonce is single use, see models.Invite.NonceHash.
+	Nonce     string    `json:"nonce"`
+	UserID    uuid.UUID `json:"user_id"`
+	CompanyID uuid.UUID `json:"company_id"`
+	jwt.RegisteredClaims
+}
+
+type InviteService struct {
+	repo        *repositories.InviteRepository
+	userRepo    *repositories.UserRepository
+	companyRepo *repositories.CompanyRepository
+	roleRepo    *repositories.RoleRepository
+	outbox      *OutboxService
+}
+
+func NewInviteService(db *gorm.DB) *InviteService {
+	return &InviteService{
+		repo:        repositories.NewInviteRepository(db),
+		userRepo:    repositories.NewUserRepository(db),
+		companyRepo: repositories.NewCompanyRepository(db),
+		roleRepo:    repositories.NewRoleRepository(db),
+		outbox:      NewOutboxService(db),
+	}
+}
+
+// Invite creates a user in the company with the given role and emails them an invite to set
+// their password.
+func (s *InviteService) Invite(companyID uuid.UUID, invitedByID *uuid.UUID, data schemas.InviteUserRequest) (*models.Invite, error) {
+	company, err := s.getCompany(companyID)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.roleRepo.GetByNames([]string{data.Role})
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) != 1 {
+		return nil, errors.ErrRoleNotFound
+	}
+	exists, err := s.userRepo.EmailExists(data.Email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.ErrEmailAlreadyExists
+	}
+
+	user, err := s.userRepo.Create(&models.User{
+		FirstName: data.FirstName,
+		LastName:  data.LastName,
+		Email:     data.Email,
+		CompanyID: &company.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = s.roleRepo.SetUserRoles(user, roles); err != nil {
+		return nil, err
+	}
+	return s.Send(user, invitedByID)
+}
+
+// Send emails the user a link to set their password and activate the account. Earlier pending
+// invites of the user are revoked, so only the latest link works. The email goes through the
+// outbox, so it is only sent once the caller's transaction commits.
+func (s *InviteService) Send(user *models.User, invitedByID *uuid.UUID) (*models.Invite, error) {
+	if err := s.repo.RevokePendingForUser(user.ID); err != nil {
+		return nil, err
+	}
+
+	nonce, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(time.Duration(config.Get().Auth.InviteExpireInMinutes) * time.Minute)
+	invite, err := s.repo.Create(&models.Invite{
+		NonceHash:   HashRefreshToken(nonce),
+		UserID:      user.ID,
+		Email:       user.Email,
+		CompanyID:   user.CompanyID,
+		InvitedByID: invitedByID,
+		Status:      models.InviteStatusPending,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := InviteClaims{
+		InviteID: invite.ID,
+		Nonce:    nonce,
+		UserID:   user.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	email := mailer.InviteEmail{FirstName: user.FirstName, ExpiresAt: expiresAt}
+	if user.CompanyID != nil {
+		claims.CompanyID = *user.CompanyID
+		company, err := s.companyRepo.GetById(*user.CompanyID)
+		if err != nil {
+			return nil, err
+		}
+		email.CompanyName = company.Name
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	secret, err := token.SignedString(inviteSecret)
+	if err != nil {
+		return nil, err
+	}
+	email.AcceptURL = InviteAcceptURL(secret)
+
+	message, err := mailer.RenderInvite(user.Email, email)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.outbox.QueueEmail(message); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// InviteAcceptURL is the frontend page that lets the invited user set their password.
+func InviteAcceptURL(token string) string {
+	return config.Get().Server.FrontendBaseURL + "/invite/accept?token=" + url.QueryEscape(token)
+}
+
+// List returns the company's invites with the given status, pending ones by default.
+// Overdue invites are marked expired first so they are not listed as pending.
+func (s *InviteService) List(companyID uuid.UUID, status string) ([]models.Invite, error) {
+	if _, err := s.getCompany(companyID); err != nil {
+		return nil, err
+	}
+	switch status {
+	case "":
+		status = models.InviteStatusPending
+	case "all":
+		status = ""
+	case models.InviteStatusPending, models.InviteStatusAccepted, models.InviteStatusRevoked, models.InviteStatusExpired:
+	default:
+		return nil, errors.ErrInvalidInviteStatus
+	}
+	if err := s.repo.ExpireOverdue(time.Now()); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByCompany(companyID, status)
+}
+
+// Resend sends the invited user a fresh link. The new invite replaces the old one, which stops working.
+func (s *InviteService) Resend(companyID, inviteID uuid.UUID, invitedByID *uuid.UUID) (*models.Invite, error) {
+	invite, err := s.getCompanyInvite(companyID, inviteID)
+	if err != nil {
+		return nil, err
+	}
+	switch invite.Status {
+	case models.InviteStatusAccepted:
+		return nil, errors.ErrInviteAlreadyAccepted
+	case models.InviteStatusRevoked:
+		return nil, errors.ErrInviteRevoked
+	}
+
+	user, err := s.userRepo.GetById(invite.UserID)
+	if err != nil || user == nil {
+		return nil, errors.ErrUserNotFound
+	}
+	return s.Send(user, invitedByID)
+}
+
+// Revoke makes a pending invite unusable.
+func (s *InviteService) Revoke(companyID, inviteID uuid.UUID) error {
+	invite, err := s.getCompanyInvite(companyID, inviteID)
+	if err != nil {
+		return err
+	}
+	switch invite.Status {
+	case models.InviteStatusAccepted:
+		return errors.ErrInviteAlreadyAccepted
+	case models.InviteStatusRevoked:
+		return nil
+	}
+	return s.repo.SetStatus(invite, models.InviteStatusRevoked)
+}
+
+// Accept validates the invite token and consumes its invite, so the token cannot be used again.
+// It returns the invited user.
+func (s *InviteService) Accept(tokenString string) (*models.User, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &InviteClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return inviteSecret, nil
+	})
+	if err != nil {
+		return nil, errors.ErrInvalidInviteToken
+	}
+
+	claims, ok := token.Claims.(*InviteClaims)
+	if !ok || !token.Valid || claims.InviteID == uuid.Nil || claims.Nonce == "" {
+		return nil, errors.ErrInvalidInviteToken
+	}
+
+	invite, err := s.repo.GetById(claims.InviteID)
+	if err != nil || invite == nil || invite.UserID != claims.UserID {
+		return nil, errors.ErrInvalidInviteToken
+	}
+	switch {
+	case invite.Status == models.InviteStatusRevoked:
+		return nil, errors.ErrInviteRevoked
+	case invite.Status == models.InviteStatusAccepted:
+		return nil, errors.ErrInviteAlreadyAccepted
+	case invite.Status == models.InviteStatusExpired, !invite.ExpiresAt.After(time.Now()):
+		return nil, errors.ErrInviteExpired
+	}
+
+	user, err := s.userRepo.GetById(invite.UserID)
+	if err != nil || user == nil {
+		return nil, errors.ErrUserNotFound
+	}
+	consumed, err := s.repo.Consume(invite, HashRefreshToken(claims.Nonce), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		// Another request accepted the invite first.
+		return nil, errors.ErrInviteAlreadyAccepted
+	}
+	return user, nil
+}
+
+func (s *InviteService) getCompany(companyID uuid.UUID) (*models.Company, error) {
+	company, err := s.companyRepo.GetById(companyID)
+	if err != nil || company == nil {
+		return nil, errors.ErrCompanyNotFound
+	}
+	return company, nil
+}
+
+func (s *InviteService) getCompanyInvite(companyID, inviteID uuid.UUID) (*models.Invite, error) {
+	if _, err := s.getCompany(companyID); err != nil {
+		return nil, err
+	}
+	invite, err := s.repo.GetCompanyInvite(companyID, inviteID)
+	if err != nil || invite == nil {
+		return nil, errors.ErrInviteNotFound
+	}
+	return invite, nil
+}
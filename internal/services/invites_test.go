@@ -0,0 +1,149 @@
+package services
+
+import (
+	"encoding/json"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/mailer"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"fleet-pulse-users-service/internal/testdb"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var emailedTokenPattern = regexp.MustCompile(`token=(\S+)`)
+
+// createInvitedUser creates a user in a new company, as an admin invite does.
+func createInvitedUser(t *testing.T, db *gorm.DB) (*models.Company, *models.User) {
+	t.Helper()
+	company := &models.Company{Name: "Alpha Freight"}
+	if err := db.Create(company).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{FirstName: "Ivy", LastName: "Invitee", Email: "ivy@example.com", CompanyID: &company.ID}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return company, user
+}
+
+// lastEmailedToken returns the token of the link in the email queued last.
+func lastEmailedToken(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	var queued models.OutboxMessage
+	if err := db.Order("created_at DESC").First(&queued).Error; err != nil {
+		t.Fatal(err)
+	}
+	var message mailer.Message
+	if err := json.Unmarshal([]byte(queued.Payload), &message); err != nil {
+		t.Fatal(err)
+	}
+	match := emailedTokenPattern.FindStringSubmatch(message.Text)
+	if match == nil {
+		t.Fatalf("no link with a token in %q", message.Text)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAcceptRejectsRevokedInvite(t *testing.T) {
+	db := testdb.Open(t)
+	invites := NewInviteService(db)
+	company, user := createInvitedUser(t, db)
+	invite, err := invites.Send(user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := lastEmailedToken(t, db)
+
+	if err = invites.Revoke(company.ID, invite.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = invites.Accept(token); err != errors.ErrInviteRevoked {
+		t.Fatalf("got %v, want ErrInviteRevoked", err)
+	}
+}
+
+func TestResendReplacesEarlierInvite(t *testing.T) {
+	db := testdb.Open(t)
+	invites := NewInviteService(db)
+	company, user := createInvitedUser(t, db)
+	invite, err := invites.Send(user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	earlierToken := lastEmailedToken(t, db)
+
+	if _, err = invites.Resend(company.ID, invite.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = invites.Accept(earlierToken); err != errors.ErrInviteRevoked {
+		t.Fatalf("earlier token: got %v, want ErrInviteRevoked", err)
+	}
+	accepted, err := invites.Accept(lastEmailedToken(t, db))
+	if err != nil {
+		t.Fatalf("resent token: %v", err)
+	}
+	if accepted.ID != user.ID {
+		t.Fatalf("got user %s, want %s", accepted.ID, user.ID)
+	}
+}
+
+func TestAcceptInviteOnlyOnce(t *testing.T) {
+	db := testdb.Open(t)
+	newTokenAuthService(t, db)
+	users := NewUserService(db)
+	_, user := createInvitedUser(t, db)
+	if _, err := NewInviteService(db).Send(user, nil); err != nil {
+		t.Fatal(err)
+	}
+	token := lastEmailedToken(t, db)
+
+	accepted, err := users.AcceptInvite(token, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted.AcceptedAt == nil {
+		t.Fatal("the acceptance was not recorded on the user")
+	}
+	// A leaked link must not set the password again.
+	if _, err = users.AcceptInvite(token, "Another-Staple-Horse-4"); err != errors.ErrInviteAlreadyAccepted {
+		t.Fatalf("replayed token: got %v, want ErrInviteAlreadyAccepted", err)
+	}
+	var stored models.User
+	if err = db.First(&stored, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(stored.Password, testPassword) {
+		t.Fatal("the replayed token changed the password")
+	}
+}
+
+func TestConsumeInviteOnlyOnce(t *testing.T) {
+	db := testdb.Open(t)
+	_, user := createInvitedUser(t, db)
+	invite, err := NewInviteService(db).Send(user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two acceptances racing each other both loaded the invite while it was pending.
+	repo := repositories.NewInviteRepository(db)
+	for i, want := range []bool{true, false} {
+		pending := *invite
+		consumed, err := repo.Consume(&pending, invite.NonceHash, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if consumed != want {
+			t.Fatalf("acceptance %d: got consumed %v, want %v", i+1, consumed, want)
+		}
+	}
+}
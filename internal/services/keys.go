@@ -0,0 +1,414 @@
+package services
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"fleet-pulse-users-service/internal/schemas"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	signingKeyBits = 2048
+	// Other replicas pick up a rotation at most this long after it happened.
+	keyRefreshInterval = time.Minute
+	// Tokens signed with an unknown kid trigger a reload at most this often.
+	keyReloadCooldown = 10 * time.Second
+	// sealedKeyPrefix marks private keys encrypted with the key encryption key. Rows without
+	// it hold a plain PEM block from before keys were encrypted, and are sealed on startup.
+	sealedKeyPrefix = "aes-gcm:"
+)
+
+// SigningKey is an RSA key pair used to sign access tokens, identified by its kid.
+// Retired keys only verify tokens until ExpiresAt.
+type SigningKey struct {
+	KID        string
+	PrivateKey *rsa.PrivateKey
+	ExpiresAt  *time.Time
+}
+
+// KeyRing holds the key used to sign new tokens and every key tokens may be verified with.
+// It is backed by the signing_keys table so that all replicas share it.
+type KeyRing struct {
+	repo *repositories.SigningKeyRepository
+	// sealer encrypts private keys before they are stored.
+	sealer   cipher.AEAD
+	reloadMu sync.Mutex
+	mu       sync.RWMutex
+	active   *SigningKey
+	keys     map[string]*SigningKey
+	loadedAt time.Time
+}
+
+var signingKeys *KeyRing
+
+// InitSigningKeys loads the process-wide key ring. When the database holds no active key yet,
+// the key from JWT_PRIVATE_KEY_FILE is imported, or a new one is generated. Private keys are
+// stored encrypted with JWT_KEY_ENCRYPTION_KEY.
+func InitSigningKeys(db *gorm.DB) (*KeyRing, error) {
+	settings := config.Get().Auth
+	sealer, err := NewKeySealer(settings.JwtKeyEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	ring := NewKeyRing(db, sealer)
+	if err = ring.bootstrap(settings.JwtPrivateKeyFile); err != nil {
+		return nil, err
+	}
+	signingKeys = ring
+	return ring, nil
+}
+
+// SigningKeys returns the key ring loaded by InitSigningKeys.
+func SigningKeys() *KeyRing {
+	if signingKeys == nil {
+		log.Fatal("Signing keys are not initialized")
+	}
+	return signingKeys
+}
+
+func NewKeyRing(db *gorm.DB, sealer cipher.AEAD) *KeyRing {
+	return &KeyRing{
+		repo:   repositories.NewSigningKeyRepository(db),
+		sealer: sealer,
+		keys:   map[string]*SigningKey{},
+	}
+}
+
+// NewKeySealer returns the AES-256-GCM cipher private keys are encrypted with, from a
+// base64 encoded 32 byte key.
+func NewKeySealer(encodedKey string) (cipher.AEAD, error) {
+	if encodedKey == "" {
+		return nil, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY is not set")
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Active returns the key new tokens are signed with.
+func (k *KeyRing) Active() *SigningKey {
+	k.refreshIfStale(keyRefreshInterval)
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Lookup returns the key with the given kid, if tokens signed with it are still accepted.
+// Unknown kids reload the ring first, as another replica may have rotated the keys.
+func (k *KeyRing) Lookup(kid string) (*SigningKey, bool) {
+	if key, ok := k.lookup(kid); ok {
+		return key, true
+	}
+	k.refreshIfStale(keyReloadCooldown)
+	return k.lookup(kid)
+}
+
+func (k *KeyRing) lookup(kid string) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[kid]
+	if !ok || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, false
+	}
+	return key, true
+}
+
+// JWKS returns the public half of every key in the ring.
+func (k *KeyRing) JWKS() schemas.JWKSResponse {
+	k.refreshIfStale(keyRefreshInterval)
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := make([]schemas.JWK, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key.JWK())
+	}
+	return schemas.JWKSResponse{Keys: keys}
+}
+
+// Rotate generates a new active key. The previous one keeps verifying tokens until the
+// longest-lived token it may have signed expires. Other replicas go on signing with it until
+// they reload, so that delay is added on top.
+func (k *KeyRing) Rotate() (*SigningKey, error) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := k.seal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	accessTokenLifetime := time.Duration(config.Get().Auth.JwtAccessTokenExpireInMinutes) * time.Minute
+	verifyUntil := time.Now().Add(accessTokenLifetime + keyRefreshInterval)
+	if err = k.repo.Rotate(&models.SigningKey{KID: key.KID, PrivateKey: sealed}, verifyUntil); err != nil {
+		return nil, err
+	}
+	if err = k.Reload(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Reload replaces the ring with the keys currently stored in the database.
+func (k *KeyRing) Reload() error {
+	rows, err := k.repo.ListUsable(time.Now())
+	if err != nil {
+		return err
+	}
+
+	var active *SigningKey
+	keys := make(map[string]*SigningKey, len(rows))
+	for _, row := range rows {
+		key, err := k.open(row)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", row.KID, err)
+		}
+		key.ExpiresAt = row.ExpiresAt
+		keys[key.KID] = key
+		if row.Status == models.SigningKeyStatusActive {
+			active = key
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if active != nil {
+		k.active = active
+	}
+	k.keys = keys
+	if k.active != nil {
+		k.keys[k.active.KID] = k.active
+	}
+	k.loadedAt = time.Now()
+	return nil
+}
+
+func (k *KeyRing) refreshIfStale(maxAge time.Duration) {
+	k.mu.RLock()
+	stale := time.Since(k.loadedAt) > maxAge
+	k.mu.RUnlock()
+	// Requests arriving while another one reloads keep using the current keys.
+	if !stale || !k.reloadMu.TryLock() {
+		return
+	}
+	defer k.reloadMu.Unlock()
+	if err := k.Reload(); err != nil {
+		log.Printf("Failed to reload signing keys: %v", err)
+	}
+}
+
+func (k *KeyRing) bootstrap(privateKeyFile string) error {
+	if err := k.sealPlaintextKeys(); err != nil {
+		return err
+	}
+	if err := k.Reload(); err != nil {
+		return err
+	}
+	if k.active != nil {
+		return nil
+	}
+
+	key, err := loadOrGenerateSigningKey(privateKeyFile)
+	if err != nil {
+		return err
+	}
+	sealed, err := k.seal(key)
+	if err != nil {
+		return err
+	}
+	// Another replica may have won the race for the first key, in which case its key is used.
+	_, createErr := k.repo.Create(&models.SigningKey{
+		KID:        key.KID,
+		PrivateKey: sealed,
+		Status:     models.SigningKeyStatusActive,
+	})
+	if err = k.Reload(); err != nil {
+		return err
+	}
+	if k.active == nil {
+		return fmt.Errorf("no active signing key: %w", createErr)
+	}
+	return nil
+}
+
+// sealPlaintextKeys encrypts keys stored before private keys were encrypted at rest.
+func (k *KeyRing) sealPlaintextKeys() error {
+	rows, err := k.repo.List()
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if strings.HasPrefix(row.PrivateKey, sealedKeyPrefix) {
+			continue
+		}
+		key, err := ParseSigningKey([]byte(row.PrivateKey))
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", row.KID, err)
+		}
+		sealed, err := k.seal(key)
+		if err != nil {
+			return err
+		}
+		if err = k.repo.SetPrivateKey(&row, sealed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seal encrypts the PEM encoded private key. The kid is authenticated along with it, so a
+// sealed key cannot be moved to another row.
+func (k *KeyRing) seal(key *SigningKey) (string, error) {
+	encoded, err := EncodeSigningKey(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, k.sealer.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := k.sealer.Seal(nonce, nonce, []byte(encoded), []byte(key.KID))
+	return sealedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts and parses a stored private key.
+func (k *KeyRing) open(row models.SigningKey) (*SigningKey, error) {
+	encoded, ok := strings.CutPrefix(row.PrivateKey, sealedKeyPrefix)
+	if !ok {
+		return nil, fmt.Errorf("private key is not encrypted")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := k.sealer.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("sealed private key is too short")
+	}
+	pemData, err := k.sealer.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(row.KID))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting private key: %w", err)
+	}
+	key, err := ParseSigningKey(pemData)
+	if err != nil {
+		return nil, err
+	}
+	if key.KID != row.KID {
+		return nil, fmt.Errorf("private key does not match kid")
+	}
+	return key, nil
+}
+
+// JWK returns the public key in JSON Web Key format.
+func (k *SigningKey) JWK() schemas.JWK {
+	return schemas.JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: k.KID,
+		N:   base64.RawURLEncoding.EncodeToString(k.PrivateKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.PrivateKey.E)).Bytes()),
+	}
+}
+
+func GenerateSigningKey() (*SigningKey, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(privateKey)
+}
+
+// ParseSigningKey reads an RSA private key from a PKCS#1 or PKCS#8 PEM block.
+func ParseSigningKey(pemData []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	if privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return NewSigningKey(privateKey)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an RSA private key, got %T", parsed)
+	}
+	return NewSigningKey(privateKey)
+}
+
+// EncodeSigningKey writes the private key as a PKCS#8 PEM block.
+func EncodeSigningKey(key *SigningKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// NewSigningKey wraps the private key and derives its kid from the RFC 7638 thumbprint.
+func NewSigningKey(privateKey *rsa.PrivateKey) (*SigningKey, error) {
+	key := &SigningKey{PrivateKey: privateKey}
+	jwk := key.JWK()
+	// Members must be in lexicographic order for the thumbprint to be canonical.
+	thumbprintInput, err := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{E: jwk.E, Kty: jwk.Kty, N: jwk.N})
+	if err != nil {
+		return nil, err
+	}
+	thumbprint := sha256.Sum256(thumbprintInput)
+	key.KID = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	return key, nil
+}
+
+// Public returns the key tokens signed with this key are verified with.
+func (k *SigningKey) Public() crypto.PublicKey {
+	return &k.PrivateKey.PublicKey
+}
+
+func loadOrGenerateSigningKey(path string) (*SigningKey, error) {
+	if path == "" {
+		log.Println("JWT_PRIVATE_KEY_FILE is not set, generating the first signing key")
+		return GenerateSigningKey()
+	}
+
+	pemData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSigningKey(pemData)
+}
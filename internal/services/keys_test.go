@@ -0,0 +1,87 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/testdb"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigningKeysAreEncryptedAtRest(t *testing.T) {
+	db := testdb.Open(t)
+	newTokenAuthService(t, db)
+
+	var row models.SigningKey
+	if err := db.First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(row.PrivateKey, sealedKeyPrefix) || strings.Contains(row.PrivateKey, "PRIVATE KEY") {
+		t.Fatalf("the private key is stored in the clear: %.40q", row.PrivateKey)
+	}
+
+	otherSealer, err := NewKeySealer("ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = NewKeyRing(db, otherSealer).Reload(); err == nil {
+		t.Fatal("the keys were decrypted with another key encryption key")
+	}
+}
+
+func TestInitSigningKeysEncryptsPlaintextKeys(t *testing.T) {
+	db := testdb.Open(t)
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := EncodeSigningKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := &models.SigningKey{KID: key.KID, PrivateKey: encoded, Status: models.SigningKeyStatusActive}
+	if err = db.Create(row).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	config.Get().Auth.JwtKeyEncryptionKey = testKeyEncryptionKey
+	ring, err := InitSigningKeys(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ring.Active().KID != key.KID {
+		t.Fatalf("active key = %s, want the stored %s", ring.Active().KID, key.KID)
+	}
+	if err = db.First(row, "kid = ?", key.KID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(row.PrivateKey, sealedKeyPrefix) {
+		t.Fatal("the plaintext key was not encrypted")
+	}
+}
+
+func TestRotateKeepsRetiredKeyUntilReplicasReload(t *testing.T) {
+	db := testdb.Open(t)
+	newTokenAuthService(t, db)
+	ring := SigningKeys()
+	retired := ring.Active()
+
+	before := time.Now()
+	if _, err := ring.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var row models.SigningKey
+	if err := db.First(&row, "kid = ?", retired.KID).Error; err != nil {
+		t.Fatal(err)
+	}
+	accessTokenLifetime := time.Duration(config.Get().Auth.JwtAccessTokenExpireInMinutes) * time.Minute
+	want := before.Add(accessTokenLifetime + keyRefreshInterval)
+	if row.ExpiresAt == nil || row.ExpiresAt.Before(want) {
+		t.Fatalf("the retired key expires at %v, want no earlier than %v", row.ExpiresAt, want)
+	}
+	if _, ok := ring.Lookup(retired.KID); !ok {
+		t.Fatal("the retired key no longer verifies tokens")
+	}
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"image/png"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+)
+
+const (
+	totpPeriod            = 30
+	totpQRCodeSize        = 256
+	recoveryCodeCount     = 10
+	recoveryCodeLength    = 10
+	recoveryCodeAlphabet  = "abcdefghjkmnpqrstuvwxyz23456789"
+	mfaChallengeLifetime  = 5 * time.Minute
+	maxMFAChallengeErrors = 5
+	// Wrong codes of a user are counted wherever a code is asked for, so starting new sign-ins
+	// or switching endpoints does not buy more guesses.
+	maxMFAFailures     = 10
+	mfaFailureWindow   = 15 * time.Minute
+	mfaLockoutDuration = 15 * time.Minute
+)
+
+var totpOptions = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// TOTPEnrollment is what the user needs to add the account to an authenticator app.
+type TOTPEnrollment struct {
+	Secret    string
+	URI       string
+	QRCodePNG []byte
+}
+
+// MFAChallengeToken is returned by a password sign-in that still needs a second factor.
+type MFAChallengeToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+type MFAService struct {
+	factorRepo       *repositories.TOTPFactorRepository
+	recoveryCodeRepo *repositories.RecoveryCodeRepository
+	challengeRepo    *repositories.MFAChallengeRepository
+	userRepo         *repositories.UserRepository
+	securityEvents   *SecurityEventService
+}
+
+func NewMFAService(db *gorm.DB) *MFAService {
+	return &MFAService{
+		factorRepo:       repositories.NewTOTPFactorRepository(db),
+		recoveryCodeRepo: repositories.NewRecoveryCodeRepository(db),
+		challengeRepo:    repositories.NewMFAChallengeRepository(db),
+		userRepo:         repositories.NewUserRepository(db),
+		securityEvents:   NewSecurityEventService(db),
+	}
+}
+
+// IsEnabled reports whether sign-ins of the user need a second factor.
+func (s *MFAService) IsEnabled(userID uuid.UUID) (bool, error) {
+	return s.factorRepo.HasConfirmedFactor(userID)
+}
+
+// Status returns whether TOTP is enabled and how many recovery codes are left.
+func (s *MFAService) Status(userID uuid.UUID) (bool, int64, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil || !enabled {
+		return false, 0, err
+	}
+	remaining, err := s.recoveryCodeRepo.CountUnused(userID)
+	return enabled, remaining, err
+}
+
+// StartTOTPEnrollment generates a new secret for the user. It only protects sign-ins once
+// ConfirmTOTP is called with a code from the authenticator app.
+func (s *MFAService) StartTOTPEnrollment(userID uuid.UUID) (*TOTPEnrollment, error) {
+	user, err := s.userRepo.GetById(userID)
+	if err != nil || user == nil {
+		return nil, errors.ErrUserNotFound
+	}
+	factor, _ := s.factorRepo.GetUserFactor(user.ID)
+	if factor != nil && factor.ConfirmedAt != nil {
+		return nil, errors.ErrMFAAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      config.Get().Auth.MFAIssuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      totpOptions.Digits,
+		Algorithm:   totpOptions.Algorithm,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Restarting an enrollment replaces the secret that was never confirmed.
+	if err = s.factorRepo.DeleteUserFactor(user.ID); err != nil {
+		return nil, err
+	}
+	if _, err = s.factorRepo.Create(&models.TOTPFactor{UserID: user.ID, Secret: key.Secret()}); err != nil {
+		return nil, err
+	}
+
+	image, err := key.Image(totpQRCodeSize, totpQRCodeSize)
+	if err != nil {
+		return nil, err
+	}
+	var qrCode bytes.Buffer
+	if err = png.Encode(&qrCode, image); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Secret: key.Secret(), URI: key.URL(), QRCodePNG: qrCode.Bytes()}, nil
+}
+
+// ConfirmTOTP turns on two-factor authentication once the user enters a first code, and
+// returns the recovery codes. They are shown this one time only.
+func (s *MFAService) ConfirmTOTP(userID uuid.UUID, code string) ([]string, error) {
+	factor, err := s.factorRepo.GetUserFactor(userID)
+	if err != nil || factor == nil {
+		return nil, errors.ErrMFAEnrollmentNotStarted
+	}
+	if factor.ConfirmedAt != nil {
+		return nil, errors.ErrMFAAlreadyEnabled
+	}
+	ok, err := s.verifyTOTP(factor, code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.ErrInvalidMFACode
+	}
+	if err = s.factorRepo.Confirm(factor, time.Now()); err != nil {
+		return nil, err
+	}
+	codes, err := s.replaceRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.recordEvent(models.SecurityEventMFAEnabled, userID)
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off after checking a current code.
+func (s *MFAService) DisableTOTP(userID uuid.UUID, code string) error {
+	if err := s.VerifyCode(userID, code); err != nil {
+		return err
+	}
+	if err := s.factorRepo.DeleteUserFactor(userID); err != nil {
+		return err
+	}
+	if err := s.recoveryCodeRepo.DeleteUserCodes(userID); err != nil {
+		return err
+	}
+	s.recordEvent(models.SecurityEventMFADisabled, userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user after checking a current code.
+func (s *MFAService) RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error) {
+	if err := s.VerifyCode(userID, code); err != nil {
+		return nil, err
+	}
+	codes, err := s.replaceRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.recordEvent(models.SecurityEventMFARecoveryCodesRegenerated, userID)
+	return codes, nil
+}
+
+// VerifyCode checks a TOTP code or, failing that, consumes a recovery code of the user. Every
+// wrong code counts towards a lockout of the user's factor, after which no code is accepted
+// for a while. Callers in a transaction must commit after ErrInvalidMFACode and ErrMFALocked,
+// see errors.IsCountedMFAFailure.
+func (s *MFAService) VerifyCode(userID uuid.UUID, code string) error {
+	factor, err := s.factorRepo.GetUserFactor(userID)
+	if err != nil || factor == nil || factor.ConfirmedAt == nil {
+		return errors.ErrMFANotEnabled
+	}
+	now := time.Now()
+	if factor.LockedUntil != nil && factor.LockedUntil.After(now) {
+		return errors.ErrMFALocked
+	}
+
+	ok, err := s.verifyTOTP(factor, code, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		ok, err = s.recoveryCodeRepo.Consume(userID, HashRefreshToken(normalizeRecoveryCode(code)), now)
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.recordEvent(models.SecurityEventMFARecoveryCodeUsed, userID)
+		}
+	}
+	if ok {
+		return s.factorRepo.ResetFailures(factor)
+	}
+	return s.recordFailure(factor, now)
+}
+
+// recordFailure counts a wrong code and locks the factor once there were too many.
+func (s *MFAService) recordFailure(factor *models.TOTPFactor, now time.Time) error {
+	failures, err := s.factorRepo.RecordFailure(factor, now, mfaFailureWindow)
+	if err != nil {
+		return err
+	}
+	if failures < maxMFAFailures {
+		return errors.ErrInvalidMFACode
+	}
+	if err = s.factorRepo.Lock(factor, now.Add(mfaLockoutDuration)); err != nil {
+		return err
+	}
+	s.recordEvent(models.SecurityEventMFALocked, factor.UserID)
+	return errors.ErrMFALocked
+}
+
+// RequireSecondFactor checks the code of a user with two-factor authentication enabled.
+// Users without it pass without a code.
+func (s *MFAService) RequireSecondFactor(userID uuid.UUID, code string) error {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+	if strings.TrimSpace(code) == "" {
+		return errors.ErrMFARequired
+	}
+	return s.VerifyCode(userID, code)
+}
+
+// CreateChallenge issues the token a password sign-in hands out instead of the token pair.
+func (s *MFAService) CreateChallenge(user *models.User, deviceName string) (*MFAChallengeToken, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(mfaChallengeLifetime)
+	_, err = s.challengeRepo.Create(&models.MFAChallenge{
+		UserID:     user.ID,
+		TokenHash:  HashRefreshToken(token),
+		DeviceName: deviceName,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MFAChallengeToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// CompleteChallenge checks the code for a challenge and consumes it. A challenge is dropped
+// after a few wrong codes, and every wrong code also counts towards the user's lockout, so a
+// new sign-in does not buy more guesses.
+func (s *MFAService) CompleteChallenge(token, code string) (*models.MFAChallenge, error) {
+	challenge, err := s.challengeRepo.GetByTokenHash(HashRefreshToken(token), time.Now())
+	if err != nil || challenge == nil {
+		return nil, errors.ErrInvalidMFAChallenge
+	}
+
+	if err = s.VerifyCode(challenge.UserID, code); err != nil {
+		if err != errors.ErrInvalidMFACode {
+			return nil, err
+		}
+		attempts, recordErr := s.challengeRepo.RecordFailure(challenge)
+		if recordErr != nil {
+			return nil, recordErr
+		}
+		if attempts >= maxMFAChallengeErrors {
+			if _, err = s.challengeRepo.Consume(challenge); err != nil {
+				return nil, err
+			}
+			s.recordEvent(models.SecurityEventMFAChallengeLocked, challenge.UserID)
+			return nil, errors.ErrInvalidMFAChallenge
+		}
+		return nil, errors.ErrInvalidMFACode
+	}
+
+	consumed, err := s.challengeRepo.Consume(challenge)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, errors.ErrInvalidMFAChallenge
+	}
+	return challenge, nil
+}
+
+// verifyTOTP accepts codes of the current time step and its neighbours to allow for clock
+// drift. An accepted step is recorded, so the same code cannot be used twice.
+func (s *MFAService) verifyTOTP(factor *models.TOTPFactor, code string, now time.Time) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpOptions.Digits.Length() {
+		return false, nil
+	}
+	current := now.Unix() / totpPeriod
+	for _, step := range []int64{current, current - 1, current + 1} {
+		expected, err := totp.GenerateCodeCustom(factor.Secret, time.Unix(step*totpPeriod, 0), totpOptions)
+		if err != nil {
+			return false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return s.factorRepo.UseStep(factor, step)
+		}
+	}
+	return false, nil
+}
+
+func (s *MFAService) replaceRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, HashRefreshToken(normalizeRecoveryCode(code)))
+	}
+	if err := s.recoveryCodeRepo.ReplaceUserCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *MFAService) recordEvent(eventType string, userID uuid.UUID) {
+	var companyID *uuid.UUID
+	if user, err := s.userRepo.GetById(userID); err == nil && user != nil {
+		companyID = user.CompanyID
+	}
+	s.securityEvents.Record(eventType, userID, companyID, nil)
+}
+
+// randomRecoveryCode returns a code like "k7mqp-x2hra", leaving out look-alike characters.
+func randomRecoveryCode() (string, error) {
+	var code strings.Builder
+	alphabetSize := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := 0; i < recoveryCodeLength; i++ {
+		if i == recoveryCodeLength/2 {
+			code.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(recoveryCodeAlphabet[n.Int64()])
+	}
+	return code.String(), nil
+}
+
+// normalizeRecoveryCode lets users type recovery codes without the dash and in any case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/testdb"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+func createMFAUser(t *testing.T, db *gorm.DB) (*models.User, *models.TOTPFactor) {
+	t.Helper()
+	password, err := HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{FirstName: "Dana", LastName: "Dispatcher", Email: "dana@example.com", Password: password}
+	if err = db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	confirmedAt := time.Now()
+	factor := &models.TOTPFactor{UserID: user.ID, Secret: testTOTPSecret, ConfirmedAt: &confirmedAt}
+	if err = db.Create(factor).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user, factor
+}
+
+func currentTOTPCode(t *testing.T) string {
+	t.Helper()
+	code, err := totp.GenerateCodeCustom(testTOTPSecret, time.Now(), totpOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestEnableTOTP(t *testing.T) {
+	db := testdb.Open(t)
+	mfa := NewMFAService(db)
+	user := createUser(t, db)
+
+	enrollment, err := mfa.StartTOTPEnrollment(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enrollment.Secret == "" || !strings.HasPrefix(enrollment.URI, "otpauth://totp/") || len(enrollment.QRCodePNG) == 0 {
+		t.Fatalf("incomplete enrollment: %+v", enrollment)
+	}
+	if enabled, _ := mfa.IsEnabled(user.ID); enabled {
+		t.Fatal("two-factor authentication was enabled before the first code")
+	}
+
+	if _, err = mfa.ConfirmTOTP(user.ID, "000000"); err != errors.ErrInvalidMFACode {
+		t.Fatalf("a wrong code got %v, want ErrInvalidMFACode", err)
+	}
+	code, err := totp.GenerateCodeCustom(enrollment.Secret, time.Now(), totpOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recoveryCodes, err := mfa.ConfirmTOTP(user.ID, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(recoveryCodes), recoveryCodeCount)
+	}
+	enabled, remaining, err := mfa.Status(user.ID)
+	if err != nil || !enabled || remaining != recoveryCodeCount {
+		t.Fatalf("status = %v with %d recovery codes (%v), want enabled with %d", enabled, remaining, err, recoveryCodeCount)
+	}
+	if _, err = mfa.StartTOTPEnrollment(user.ID); err != errors.ErrMFAAlreadyEnabled {
+		t.Fatalf("a second enrollment got %v, want ErrMFAAlreadyEnabled", err)
+	}
+}
+
+func TestLoginWithMFAChallenge(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user, _ := createMFAUser(t, db)
+
+	accessToken, refreshToken, challenge, err := authService.LoginUser(
+		schemas.LoginUserRequest{Email: user.Email, Password: testPassword}, ClientInfo{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accessToken != "" || refreshToken != "" || challenge == nil {
+		t.Fatal("a password alone signed in a user with two-factor authentication")
+	}
+
+	if _, _, err = authService.LoginWithMFA(challenge.Token, "000000", ClientInfo{}); err != errors.ErrInvalidMFACode {
+		t.Fatalf("a wrong code got %v, want ErrInvalidMFACode", err)
+	}
+	accessToken, _, err = authService.LoginWithMFA(challenge.Token, currentTOTPCode(t), ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = authService.ValidateAccessToken(accessToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = authService.LoginWithMFA(challenge.Token, currentTOTPCode(t), ClientInfo{}); err != errors.ErrInvalidMFAChallenge {
+		t.Fatalf("a replayed challenge got %v, want ErrInvalidMFAChallenge", err)
+	}
+}
+
+func TestTOTPCodeWorksOnce(t *testing.T) {
+	db := testdb.Open(t)
+	mfa := NewMFAService(db)
+	user, _ := createMFAUser(t, db)
+
+	code := currentTOTPCode(t)
+	if err := mfa.VerifyCode(user.ID, code); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfa.VerifyCode(user.ID, code); err != errors.ErrInvalidMFACode {
+		t.Fatalf("a reused code got %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestRecoveryCodeWorksOnce(t *testing.T) {
+	db := testdb.Open(t)
+	mfa := NewMFAService(db)
+	user, _ := createMFAUser(t, db)
+	codes, err := mfa.replaceRecoveryCodes(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Users may type the code without the dash and in upper case.
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if err = mfa.VerifyCode(user.ID, typed); err != nil {
+		t.Fatal(err)
+	}
+	if err = mfa.VerifyCode(user.ID, codes[0]); err != errors.ErrInvalidMFACode {
+		t.Fatalf("a used recovery code got %v, want ErrInvalidMFACode", err)
+	}
+	if err = mfa.VerifyCode(user.ID, codes[1]); err != nil {
+		t.Fatalf("another recovery code stopped working: %v", err)
+	}
+	if _, remaining, _ := mfa.Status(user.ID); remaining != recoveryCodeCount-2 {
+		t.Fatalf("%d recovery codes left, want %d", remaining, recoveryCodeCount-2)
+	}
+}
+
+func TestVerifyCodeLocksAfterTooManyWrongCodes(t *testing.T) {
+	db := testdb.Open(t)
+	mfa := NewMFAService(db)
+	user, _ := createMFAUser(t, db)
+
+	// Wrong codes count alike no matter where they are entered, and new challenges do not
+	// reset the count.
+	for i := 1; i < maxMFAFailures; i++ {
+		var err error
+		switch i % 3 {
+		case 0:
+			err = mfa.VerifyCode(user.ID, "000000")
+		case 1:
+			err = mfa.RequireSecondFactor(user.ID, "000000")
+		case 2:
+			challenge, createErr := mfa.CreateChallenge(user, "")
+			if createErr != nil {
+				t.Fatal(createErr)
+			}
+			_, err = mfa.CompleteChallenge(challenge.Token, "000000")
+		}
+		if err != errors.ErrInvalidMFACode {
+			t.Fatalf("wrong code %d: got %v, want ErrInvalidMFACode", i, err)
+		}
+	}
+	if err := mfa.VerifyCode(user.ID, "000000"); err != errors.ErrMFALocked {
+		t.Fatalf("got %v, want ErrMFALocked", err)
+	}
+	if err := mfa.VerifyCode(user.ID, currentTOTPCode(t)); err != errors.ErrMFALocked {
+		t.Fatalf("correct code while locked: got %v, want ErrMFALocked", err)
+	}
+}
+
+func TestVerifyCodeResetsFailures(t *testing.T) {
+	db := testdb.Open(t)
+	mfa := NewMFAService(db)
+	user, factor := createMFAUser(t, db)
+
+	for i := 1; i < maxMFAFailures; i++ {
+		if err := mfa.VerifyCode(user.ID, "000000"); err != errors.ErrInvalidMFACode {
+			t.Fatalf("got %v, want ErrInvalidMFACode", err)
+		}
+	}
+	if err := mfa.VerifyCode(user.ID, currentTOTPCode(t)); err != nil {
+		t.Fatalf("correct code: %v", err)
+	}
+	if err := db.First(factor, "id = ?", factor.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if factor.FailedAttempts != 0 || factor.LockedUntil != nil {
+		t.Fatalf("failures not reset: %d, locked until %v", factor.FailedAttempts, factor.LockedUntil)
+	}
+}
+
+func TestVerifyCodeStartsNewFailureWindow(t *testing.T) {
+	db := testdb.Open(t)
+	mfa := NewMFAService(db)
+	user, factor := createMFAUser(t, db)
+
+	windowStart := time.Now().Add(-2 * mfaFailureWindow)
+	err := db.Model(factor).Updates(map[string]interface{}{
+		"failed_attempts":      maxMFAFailures - 1,
+		"failure_window_start": windowStart,
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mfa.VerifyCode(user.ID, "000000"); err != errors.ErrInvalidMFACode {
+		t.Fatalf("got %v, want ErrInvalidMFACode", err)
+	}
+	if err = db.First(factor, "id = ?", factor.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if factor.FailedAttempts != 1 {
+		t.Fatalf("failed attempts = %d, want 1 in a new window", factor.FailedAttempts)
+	}
+}
+
+func TestChallengeRecordFailureReadsCountFromDatabase(t *testing.T) {
+	db := testdb.Open(t)
+	mfa := NewMFAService(db)
+	user, _ := createMFAUser(t, db)
+	token, err := mfa.CreateChallenge(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two requests holding the same challenge must not both count from the value they loaded.
+	first, _ := mfa.challengeRepo.GetByTokenHash(HashRefreshToken(token.Token), time.Now())
+	second, _ := mfa.challengeRepo.GetByTokenHash(HashRefreshToken(token.Token), time.Now())
+	if _, err = mfa.challengeRepo.RecordFailure(first); err != nil {
+		t.Fatal(err)
+	}
+	attempts, err := mfa.challengeRepo.RecordFailure(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+}
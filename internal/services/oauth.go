@@ -0,0 +1,441 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fleet-pulse-users-service/internal"
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"fleet-pulse-users-service/internal/schemas"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	TokenTypeAccessToken  = "access_token"
+	TokenTypeRefreshToken = "refresh_token"
+)
+
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+
+	ResponseTypeCode          = "code"
+	CodeChallengeMethodS256   = "S256"
+	authorizationCodeLifetime = 5 * time.Minute
+	minCodeVerifierLength     = 43
+	maxCodeVerifierLength     = 128
+)
+
+type OAuthService struct {
+	db                     *gorm.DB
+	authService            *AuthService
+	clientService          *ClientService
+	userRepository         *repositories.UserRepository
+	refreshTokenRepository *repositories.RefreshTokenRepository
+	sessionRepository      *repositories.SessionRepository
+	codeRepository         *repositories.AuthorizationCodeRepository
+	consentRepository      *repositories.OAuthConsentRepository
+	securityEvents         *SecurityEventService
+}
+
+func NewOAuthService(db *gorm.DB) *OAuthService {
+	return &OAuthService{
+		db:                     db,
+		authService:            NewAuthService(db),
+		clientService:          NewClientService(db),
+		userRepository:         repositories.NewUserRepository(db),
+		refreshTokenRepository: repositories.NewRefreshTokenRepository(db),
+		sessionRepository:      repositories.NewSessionRepository(db),
+		codeRepository:         repositories.NewAuthorizationCodeRepository(db),
+		consentRepository:      repositories.NewOAuthConsentRepository(db),
+		securityEvents:         NewSecurityEventService(db),
+	}
+}
+
+// ClientCredentials identify the client calling the token endpoint.
+type ClientCredentials struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// ValidateAuthorizationRequest checks an authorization request before the user is asked
+// to sign in. It returns the client and the scopes it would be granted.
+func (s *OAuthService) ValidateAuthorizationRequest(req schemas.AuthorizationRequest) (*models.Client, []string, error) {
+	client, err := s.clientService.GetClient(req.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Codes are only ever sent to a redirect URI registered for the client.
+	if !slices.Contains(client.RedirectURIList(), req.RedirectURI) {
+		return nil, nil, fmt.Errorf("%w: redirect_uri is not registered for the client", errors.ErrInvalidRequest)
+	}
+	if req.ResponseType != ResponseTypeCode {
+		return nil, nil, errors.ErrUnsupportedResponseType
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return nil, nil, fmt.Errorf("%w: a PKCE code_challenge with the S256 method is required", errors.ErrInvalidRequest)
+	}
+
+	scopes, err := GrantScopes(client, req.Scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, scopes, nil
+}
+
+// Authorize signs the user in and issues an authorization code, returning the URI the user
+// agent is sent back to. Users are asked for consent again whenever a client requests scopes
+// they have not granted it yet.
+func (s *OAuthService) Authorize(req schemas.AuthorizeRequest) (string, error) {
+	client, scopes, err := s.ValidateAuthorizationRequest(req.AuthorizationRequest)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.authService.Authenticate(req.Email, req.Password)
+	if err != nil {
+		return "", err
+	}
+	if err = s.authService.mfa.RequireSecondFactor(user.ID, req.MFACode); err != nil {
+		return "", err
+	}
+	// Clients of a company are only available to its members.
+	if client.CompanyID != nil && (user.CompanyID == nil || *user.CompanyID != *client.CompanyID) {
+		return "", errors.ErrAccessDenied
+	}
+
+	if req.Approve != nil && !*req.Approve {
+		return redirectURI(req.RedirectURI, map[string]string{"error": "access_denied", "state": req.State}), nil
+	}
+	consent, _ := s.consentRepository.GetUserConsent(user.ID, client.ClientID)
+	consented := consent != nil && len(intersectStrings(scopes, consent.ScopeList())) == len(scopes)
+	if !consented {
+		if req.Approve == nil {
+			return "", errors.ErrConsentRequired
+		}
+		granted := scopes
+		if consent != nil {
+			granted = uniqueStrings(append(consent.ScopeList(), scopes...))
+		}
+		err = s.consentRepository.Save(&models.OAuthConsent{
+			UserID:   user.ID,
+			ClientID: client.ClientID,
+			Scopes:   strings.Join(granted, " "),
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if err = s.codeRepository.DeleteExpired(now); err != nil {
+		return "", err
+	}
+	_, err = s.codeRepository.Create(&models.AuthorizationCode{
+		CodeHash:      HashRefreshToken(code),
+		ClientID:      client.ClientID,
+		UserID:        user.ID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        strings.Join(scopes, " "),
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		ExpiresAt:     now.Add(authorizationCodeLifetime),
+	})
+	if err != nil {
+		return "", err
+	}
+	return redirectURI(req.RedirectURI, map[string]string{"code": code, "state": req.State}), nil
+}
+
+// Token handles a request to the token endpoint. The device describes the user agent
+// sessions opened by the authorization code grant are attributed to.
+func (s *OAuthService) Token(credentials ClientCredentials, req schemas.TokenRequest, device ClientInfo) (*schemas.TokenResponse, error) {
+	switch req.GrantType {
+	case GrantTypeClientCredentials:
+		return s.clientCredentialsToken(credentials, req.Scope)
+	case GrantTypeAuthorizationCode:
+		return s.authorizationCodeToken(credentials, req, device)
+	case GrantTypeRefreshToken:
+		return s.refreshToken(credentials, req.RefreshToken, device)
+	default:
+		return nil, errors.ErrUnsupportedGrantType
+	}
+}
+
+// clientCredentialsToken issues an access token for the client itself. Its permissions are
+// the granted scopes and its company, if any, is the one the client is bound to.
+func (s *OAuthService) clientCredentialsToken(credentials ClientCredentials, scope string) (*schemas.TokenResponse, error) {
+	client, err := s.clientService.Authenticate(credentials.ClientID, credentials.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	// A public client has no secret, so anyone could act as it.
+	if client.Public {
+		return nil, errors.ErrUnauthorizedClient
+	}
+	scopes, err := GrantScopes(client, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	lifetime := accessTokenLifetime()
+	accessToken, err := s.authService.GenerateJWT(&Claims{
+		CompanyID:   client.CompanyID,
+		Permissions: scopes,
+		ClientID:    client.ClientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: client.ClientID,
+		},
+	}, lifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schemas.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(lifetime.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// authorizationCodeToken exchanges an authorization code for a new session of the user in
+// the client. A replayed code ends the session it was first exchanged for.
+func (s *OAuthService) authorizationCodeToken(credentials ClientCredentials, req schemas.TokenRequest, device ClientInfo) (*schemas.TokenResponse, error) {
+	client, err := s.clientService.Authenticate(credentials.ClientID, credentials.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := s.codeRepository.GetByCode(HashRefreshToken(req.Code))
+	if err != nil || code == nil || code.ClientID != client.ClientID {
+		return nil, errors.ErrInvalidGrant
+	}
+	if code.ConsumedAt != nil {
+		return nil, s.handleAuthorizationCodeReuse(code)
+	}
+	if time.Now().After(code.ExpiresAt) || code.RedirectURI != req.RedirectURI {
+		return nil, errors.ErrInvalidGrant
+	}
+	if !VerifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		return nil, fmt.Errorf("%w: code_verifier does not match the code_challenge", errors.ErrInvalidGrant)
+	}
+
+	consumed, err := s.codeRepository.Consume(code)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, s.handleAuthorizationCodeReuse(code)
+	}
+
+	user, err := s.userRepository.GetById(code.UserID)
+	if err != nil || user == nil {
+		return nil, errors.ErrInvalidGrant
+	}
+	accessToken, refreshToken, session, err := s.authService.StartClientSession(user, client, code.ScopeList(), device)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.codeRepository.SetSession(code, session.ID); err != nil {
+		return nil, err
+	}
+
+	response := &schemas.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenLifetime().Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        session.Scopes,
+	}
+	if slices.Contains(session.ScopeList(), ScopeOpenID) {
+		response.IDToken, err = s.authService.GenerateIDToken(user, client.ClientID, code.Nonce, session.ScopeList(), code.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// refreshToken rotates the refresh token of a session the client opened.
+func (s *OAuthService) refreshToken(credentials ClientCredentials, rawRefreshToken string, device ClientInfo) (*schemas.TokenResponse, error) {
+	client, err := s.clientService.Authenticate(credentials.ClientID, credentials.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if rawRefreshToken == "" {
+		return nil, fmt.Errorf("%w: refresh_token is required", errors.ErrInvalidRequest)
+	}
+
+	accessToken, refreshToken, session, err := s.authService.RefreshClientSession(rawRefreshToken, client.ClientID, device)
+	switch err {
+	case nil:
+	case errors.ErrInvalidToken, errors.ErrExpiredToken, errors.ErrRefreshTokenReused:
+		return nil, errors.ErrInvalidGrant
+	default:
+		return nil, err
+	}
+
+	response := &schemas.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenLifetime().Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        session.Scopes,
+	}
+	if slices.Contains(session.ScopeList(), ScopeOpenID) {
+		user, err := s.userRepository.GetById(session.UserID)
+		if err != nil || user == nil {
+			return nil, errors.ErrInvalidGrant
+		}
+		response.IDToken, err = s.authService.GenerateIDToken(user, client.ClientID, "", session.ScopeList(), session.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// handleAuthorizationCodeReuse ends the session a replayed code was exchanged for, since the
+// code may have been intercepted (RFC 6749, section 4.1.2).
+func (s *OAuthService) handleAuthorizationCodeReuse(code *models.AuthorizationCode) error {
+	if code.SessionID != nil {
+		if err := s.sessionRepository.DeleteById(*code.SessionID); err != nil {
+			return err
+		}
+	}
+	s.securityEvents.Record(models.SecurityEventAuthorizationCodeReuse, code.UserID, nil, map[string]interface{}{
+		"client_id":             code.ClientID,
+		"authorization_code_id": code.ID.String(),
+	})
+	return errors.ErrInvalidGrant
+}
+
+// VerifyCodeChallenge checks a PKCE code verifier against its S256 challenge (RFC 7636).
+func VerifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return false
+	}
+	digest := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// redirectURI appends the non-empty parameters to the client's redirect URI.
+func redirectURI(base string, params map[string]string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	query := u.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func accessTokenLifetime() time.Duration {
+	return time.Duration(config.Get().Auth.JwtAccessTokenExpireInMinutes) * time.Minute
+}
+
+// Introspect reports whether the token is currently active, as described by RFC 7662.
+// The hint only decides which token type is tried first. Tokens of other companies than
+// the caller's are reported inactive, just like unknown ones.
+func (s *OAuthService) Introspect(token, tokenTypeHint string) schemas.IntrospectionResponse {
+	lookups := []func(string) (schemas.IntrospectionResponse, bool){s.introspectAccessToken, s.introspectRefreshToken}
+	if tokenTypeHint == TokenTypeRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		if response, ok := lookup(token); ok {
+			return response
+		}
+	}
+	return schemas.IntrospectionResponse{Active: false}
+}
+
+func (s *OAuthService) introspectAccessToken(token string) (schemas.IntrospectionResponse, bool) {
+	claims, err := s.authService.ValidateAccessToken(token)
+	if err != nil || !s.visibleToCaller(claims.CompanyID) {
+		return schemas.IntrospectionResponse{}, false
+	}
+	// The signature outlives the user, so tokens of deleted users are checked against the database.
+	if claims.UserID != "" {
+		userID, err := uuid.Parse(claims.UserID)
+		if err != nil {
+			return schemas.IntrospectionResponse{}, false
+		}
+		if user, err := s.userRepository.GetById(userID); err != nil || user == nil {
+			return schemas.IntrospectionResponse{}, false
+		}
+	}
+
+	response := schemas.IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(claims.Permissions, " "),
+		ClientID:  claims.ClientID,
+		Username:  claims.Email,
+		TokenType: TokenTypeAccessToken,
+		Sub:       claims.Subject,
+		CompanyID: claims.CompanyID,
+		Jti:       claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		response.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.Iat = claims.IssuedAt.Unix()
+	}
+	return response, true
+}
+
+// introspectRefreshToken looks the token up through the tenant-scoped repository, so that
+// refresh tokens of other companies are not found at all.
+func (s *OAuthService) introspectRefreshToken(token string) (schemas.IntrospectionResponse, bool) {
+	tokenObj, err := s.refreshTokenRepository.GetByToken(HashRefreshToken(token))
+	if err != nil || tokenObj == nil {
+		return schemas.IntrospectionResponse{}, false
+	}
+	if tokenObj.ConsumedAt != nil || tokenObj.RevokedAt != nil || time.Now().After(tokenObj.ExpiresAt) {
+		return schemas.IntrospectionResponse{}, false
+	}
+
+	return schemas.IntrospectionResponse{
+		Active:    true,
+		TokenType: TokenTypeRefreshToken,
+		Sub:       tokenObj.UserID.String(),
+		CompanyID: tokenObj.CompanyID,
+		Exp:       tokenObj.ExpiresAt.Unix(),
+		Iat:       tokenObj.CreatedAt.Unix(),
+	}, true
+}
+
+// visibleToCaller applies the tenant scope of the request to a token's company.
+func (s *OAuthService) visibleToCaller(companyID *uuid.UUID) bool {
+	callerCompanyID, ok := internal.TenantFromContext(s.db.Statement.Context)
+	if !ok {
+		return true
+	}
+	if callerCompanyID == nil || companyID == nil {
+		return callerCompanyID == nil && companyID == nil
+	}
+	return *callerCompanyID == *companyID
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	stderrors "errors"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/testdb"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// The example of RFC 7636, appendix B.
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	testRedirectURI   = "https://dispatch.example.com/callback"
+)
+
+func createPublicClient(t *testing.T, db *gorm.DB, clientID string) *models.Client {
+	t.Helper()
+	client := &models.Client{
+		ClientID:     clientID,
+		Name:         "Dispatch board",
+		Scopes:       "openid email",
+		RedirectURIs: testRedirectURI,
+		Public:       true,
+	}
+	if err := db.Create(client).Error; err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func authorizationRequest(clientID string) schemas.AuthorizationRequest {
+	return schemas.AuthorizationRequest{
+		ResponseType:        ResponseTypeCode,
+		ClientID:            clientID,
+		RedirectURI:         testRedirectURI,
+		CodeChallenge:       testCodeChallenge,
+		CodeChallengeMethod: CodeChallengeMethodS256,
+	}
+}
+
+// authorizeCode signs the user in to the client and returns the code sent to the redirect URI.
+func authorizeCode(t *testing.T, oauth *OAuthService, client *models.Client, user *models.User) string {
+	t.Helper()
+	approve := true
+	location, err := oauth.Authorize(schemas.AuthorizeRequest{
+		AuthorizationRequest: authorizationRequest(client.ClientID),
+		Email:                user.Email,
+		Password:             testPassword,
+		Approve:              &approve,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redirect.Query().Get("code")
+}
+
+func exchangeCode(oauth *OAuthService, clientID, code, verifier string) (*schemas.TokenResponse, error) {
+	return oauth.Token(ClientCredentials{ClientID: clientID}, schemas.TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: verifier,
+	}, ClientInfo{})
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	if !VerifyCodeChallenge(testCodeVerifier, testCodeChallenge) {
+		t.Fatal("the RFC 7636 example was rejected")
+	}
+	if VerifyCodeChallenge(testCodeVerifier[1:]+"x", testCodeChallenge) {
+		t.Fatal("another verifier was accepted")
+	}
+	if VerifyCodeChallenge(testCodeChallenge, testCodeChallenge) {
+		t.Fatal("the challenge itself was accepted as verifier")
+	}
+	if VerifyCodeChallenge("", "47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU") {
+		t.Fatal("an empty verifier was accepted")
+	}
+}
+
+func TestAuthorizationRequestRequiresPKCE(t *testing.T) {
+	db := testdb.Open(t)
+	client := createPublicClient(t, db, "dispatch-board")
+	oauth := NewOAuthService(db)
+
+	withoutChallenge := authorizationRequest(client.ClientID)
+	withoutChallenge.CodeChallenge = ""
+	plainMethod := authorizationRequest(client.ClientID)
+	plainMethod.CodeChallengeMethod = "plain"
+	for _, req := range []schemas.AuthorizationRequest{withoutChallenge, plainMethod} {
+		if _, _, err := oauth.ValidateAuthorizationRequest(req); !stderrors.Is(err, errors.ErrInvalidRequest) {
+			t.Fatalf("challenge %q with method %q: got %v, want ErrInvalidRequest", req.CodeChallenge, req.CodeChallengeMethod, err)
+		}
+	}
+}
+
+func TestAuthorizationCodeExchange(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	client := createPublicClient(t, db, "dispatch-board")
+	oauth := NewOAuthService(db)
+	code := authorizeCode(t, oauth, client, user)
+
+	for _, verifier := range []string{"", testCodeChallenge} {
+		if _, err := exchangeCode(oauth, client.ClientID, code, verifier); !stderrors.Is(err, errors.ErrInvalidGrant) {
+			t.Fatalf("verifier %q: got %v, want ErrInvalidGrant", verifier, err)
+		}
+	}
+	// Codes are bound to the client they were issued to.
+	other := createPublicClient(t, db, "other-board")
+	if _, err := exchangeCode(oauth, other.ClientID, code, testCodeVerifier); !stderrors.Is(err, errors.ErrInvalidGrant) {
+		t.Fatalf("other client: got %v, want ErrInvalidGrant", err)
+	}
+
+	response, err := exchangeCode(oauth, client.ClientID, code, testCodeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := authService.ValidateAccessToken(response.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != user.ID.String() || claims.ClientID != client.ClientID {
+		t.Fatalf("got a token of %s for %q, want %s for %q", claims.UserID, claims.ClientID, user.ID, client.ClientID)
+	}
+	if response.IDToken == "" {
+		t.Fatal("no ID token for the openid scope")
+	}
+}
+
+func TestAuthorizationCodeReplayEndsSession(t *testing.T) {
+	db := testdb.Open(t)
+	newTokenAuthService(t, db)
+	user := createUser(t, db)
+	client := createPublicClient(t, db, "dispatch-board")
+	oauth := NewOAuthService(db)
+	code := authorizeCode(t, oauth, client, user)
+
+	response, err := exchangeCode(oauth, client.ClientID, code, testCodeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = exchangeCode(oauth, client.ClientID, code, testCodeVerifier); !stderrors.Is(err, errors.ErrInvalidGrant) {
+		t.Fatalf("replayed code: got %v, want ErrInvalidGrant", err)
+	}
+
+	// The code may have been intercepted, so the session it opened is ended.
+	refresh := schemas.TokenRequest{GrantType: GrantTypeRefreshToken, RefreshToken: response.RefreshToken}
+	if _, err = oauth.Token(ClientCredentials{ClientID: client.ClientID}, refresh, ClientInfo{}); !stderrors.Is(err, errors.ErrInvalidGrant) {
+		t.Fatalf("refresh token of the replayed code: got %v, want ErrInvalidGrant", err)
+	}
+	var sessions, events int64
+	db.Model(&models.Session{}).Where("user_id = ?", user.ID).Count(&sessions)
+	if sessions != 0 {
+		t.Fatalf("got %d sessions, want the session of the code ended", sessions)
+	}
+	db.Model(&models.SecurityEvent{}).Where("type = ?", models.SecurityEventAuthorizationCodeReuse).Count(&events)
+	if events != 1 {
+		t.Fatalf("got %d code reuse events, want 1", events)
+	}
+}
+
+// signAccessToken signs the claims as they are, so tests can issue tokens in the past.
+func signAccessToken(t *testing.T, claims *Claims) string {
+	t.Helper()
+	key := SigningKeys().Active()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.KID
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// pastAccessToken returns an access token of the user issued a minute ago and expiring in expiresIn.
+func pastAccessToken(t *testing.T, authService *AuthService, user *models.User, expiresIn time.Duration) string {
+	t.Helper()
+	claims, err := authService.UserClaims(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	claims.ID = uuid.NewString()
+	claims.IssuedAt = jwt.NewNumericDate(now.Add(-time.Minute))
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(expiresIn))
+	return signAccessToken(t, claims)
+}
+
+func TestIntrospectActiveTokens(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	oauth := NewOAuthService(db)
+	accessToken, claims, refreshToken := signIn(t, authService, user.Email)
+
+	access := oauth.Introspect(accessToken, "")
+	if !access.Active || access.TokenType != TokenTypeAccessToken || access.Sub != user.ID.String() || access.Jti != claims.ID {
+		t.Fatalf("access token: got %+v, want it active for %s", access, user.ID)
+	}
+	// The hint only changes the order of the lookups.
+	refresh := oauth.Introspect(refreshToken, TokenTypeAccessToken)
+	if !refresh.Active || refresh.TokenType != TokenTypeRefreshToken || refresh.Sub != user.ID.String() {
+		t.Fatalf("refresh token: got %+v, want it active for %s", refresh, user.ID)
+	}
+}
+
+func TestIntrospectInactiveTokens(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	oauth := NewOAuthService(db)
+
+	revokedAccessToken, revokedClaims, _ := signIn(t, authService, user.Email)
+	if err := authService.RevokeAccessToken(revokedClaims); err != nil {
+		t.Fatal(err)
+	}
+	_, _, consumedRefreshToken := signIn(t, authService, user.Email)
+	if _, _, err := authService.RefreshAccessToken(consumedRefreshToken, ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	_, _, loggedOutRefreshToken := signIn(t, authService, user.Email)
+	if err := authService.Logout(loggedOutRefreshToken, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{
+		"expired access token":   pastAccessToken(t, authService, user, -time.Second),
+		"revoked access token":   revokedAccessToken,
+		"consumed refresh token": consumedRefreshToken,
+		"logged out session":     loggedOutRefreshToken,
+		"unknown token":          "not-a-token",
+	} {
+		if response := oauth.Introspect(token, ""); response != (schemas.IntrospectionResponse{}) {
+			t.Errorf("%s: got %+v, want only active false", name, response)
+		}
+	}
+}
+
+func TestIntrospectTokensOfRemovedMember(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	company := &models.Company{Name: "Alpha Freight"}
+	if err := db.Create(company).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(user).Update("company_id", company.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	oauth := NewOAuthService(db)
+	accessToken := pastAccessToken(t, authService, user, time.Hour)
+	_, _, refreshToken := signIn(t, authService, user.Email)
+	if !oauth.Introspect(accessToken, "").Active {
+		t.Fatal("the access token of a member is inactive")
+	}
+
+	if err := NewCompanyService(db).RemoveMember(company.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"access token": accessToken, "refresh token": refreshToken} {
+		if oauth.Introspect(token, "").Active {
+			t.Errorf("%s issued before the removal: got active, want inactive", name)
+		}
+	}
+}
+
+func TestIntrospectTokensOfDeletedUser(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	oauth := NewOAuthService(db)
+	accessToken, _, refreshToken := signIn(t, authService, user.Email)
+	if !oauth.Introspect(accessToken, "").Active {
+		t.Fatal("the access token of an existing user is inactive")
+	}
+
+	if err := db.Delete(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"access token": accessToken, "refresh token": refreshToken} {
+		if response := oauth.Introspect(token, ""); response != (schemas.IntrospectionResponse{}) {
+			t.Errorf("%s of a deleted user: got %+v, want only active false", name, response)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/schemas"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// OpenID Connect scopes. Clients may be granted them besides permissions.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var OpenIDScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// IDTokenClaims are carried by OpenID Connect ID tokens. Profile and email claims are only
+// filled in when the matching scope was granted.
+type IDTokenClaims struct {
+	Nonce      string           `json:"nonce,omitempty"`
+	AuthTime   *jwt.NumericDate `json:"auth_time,omitempty"`
+	Name       string           `json:"name,omitempty"`
+	GivenName  string           `json:"given_name,omitempty"`
+	FamilyName string           `json:"family_name,omitempty"`
+	Email      string           `json:"email,omitempty"`
+	UpdatedAt  int64            `json:"updated_at,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateIDToken issues an ID token telling the client who signed in and when.
+func (s AuthService) GenerateIDToken(user *models.User, clientID, nonce string, scopes []string, authTime time.Time) (string, error) {
+	now := time.Now()
+	info := userInfo(user, scopes)
+	claims := &IDTokenClaims{
+		Nonce:      nonce,
+		AuthTime:   jwt.NewNumericDate(authTime),
+		Name:       info.Name,
+		GivenName:  info.GivenName,
+		FamilyName: info.FamilyName,
+		Email:      info.Email,
+		UpdatedAt:  info.UpdatedAt,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.Get().Auth.Issuer,
+			Subject:   user.ID.String(),
+			Audience:  jwt.ClaimStrings{clientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenLifetime())),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return signJWT(claims)
+}
+
+// UserInfo returns the claims about the user an access token was issued to. Tokens of
+// sessions opened through an OAuth client need the openid scope and only see the claims
+// of the scopes granted to the client.
+func (s AuthService) UserInfo(userID uuid.UUID, sessionID *uuid.UUID) (*schemas.UserInfoResponse, error) {
+	scopes := OpenIDScopes
+	if sessionID != nil {
+		session, err := s.sessionRepository.GetById(*sessionID)
+		if err != nil || session == nil {
+			return nil, errors.ErrInvalidToken
+		}
+		if session.ClientID != nil {
+			scopes = session.ScopeList()
+		}
+	}
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return nil, errors.ErrPermissionDenied
+	}
+
+	user, err := s.userRepository.GetById(userID)
+	if err != nil || user == nil {
+		return nil, errors.ErrInvalidToken
+	}
+	info := userInfo(user, scopes)
+	return &info, nil
+}
+
+func userInfo(user *models.User, scopes []string) schemas.UserInfoResponse {
+	info := schemas.UserInfoResponse{Sub: user.ID.String()}
+	if slices.Contains(scopes, ScopeProfile) {
+		info.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		info.GivenName = user.FirstName
+		info.FamilyName = user.LastName
+		info.UpdatedAt = user.UpdatedAt.Unix()
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		info.Email = user.Email
+	}
+	return info
+}
+
+// OpenIDConfiguration describes this service as an OpenID Connect provider. It fails while
+// no sign-in page is configured as the authorization endpoint, since clients would send
+// browsers to the JSON authorize API otherwise.
+func OpenIDConfiguration() (schemas.OpenIDConfiguration, error) {
+	auth := config.Get().Auth
+	if auth.AuthorizationEndpoint == "" || strings.TrimSuffix(auth.AuthorizationEndpoint, "/") == auth.Issuer+"/v1/oauth/authorize" {
+		return schemas.OpenIDConfiguration{}, errors.ErrAuthorizationEndpointNotConfigured
+	}
+	return schemas.OpenIDConfiguration{
+		Issuer:                            auth.Issuer,
+		AuthorizationEndpoint:             auth.AuthorizationEndpoint,
+		TokenEndpoint:                     auth.Issuer + "/v1/oauth/token",
+		UserinfoEndpoint:                  auth.Issuer + "/v1/oauth/userinfo",
+		IntrospectionEndpoint:             auth.Issuer + "/v1/oauth/introspect",
+		JwksURI:                           auth.Issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   OpenIDScopes,
+		ResponseTypesSupported:            []string{ResponseTypeCode},
+		GrantTypesSupported:               []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{jwt.SigningMethodRS256.Alg()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{CodeChallengeMethodS256},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "given_name", "family_name", "email", "updated_at",
+		},
+	}, nil
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/errors"
+	"testing"
+)
+
+func TestOpenIDConfigurationNeedsSignInPage(t *testing.T) {
+	auth := &config.Get().Auth
+	configured := auth.AuthorizationEndpoint
+	t.Cleanup(func() { auth.AuthorizationEndpoint = configured })
+
+	for _, endpoint := range []string{"", auth.Issuer + "/v1/oauth/authorize"} {
+		auth.AuthorizationEndpoint = endpoint
+		if _, err := OpenIDConfiguration(); err != errors.ErrAuthorizationEndpointNotConfigured {
+			t.Fatalf("endpoint %q: got %v, want ErrAuthorizationEndpointNotConfigured", endpoint, err)
+		}
+	}
+
+	auth.AuthorizationEndpoint = "https://app.example.com/oauth/authorize"
+	discovery, err := OpenIDConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if discovery.AuthorizationEndpoint != auth.AuthorizationEndpoint {
+		t.Fatalf("got %q, want the sign-in page", discovery.AuthorizationEndpoint)
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fleet-pulse-users-service/internal/mailer"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	outboxPollInterval = 5 * time.Second
+	outboxBatchSize    = 20
+	// Claimed messages are left alone by other dispatchers this long. It has to outlast the
+	// delivery of a whole batch, which the SMTP timeout bounds.
+	outboxLease = 15 * time.Minute
+	// Messages still failing after this many attempts are moved to the dead status.
+	outboxMaxAttempts     = 10
+	outboxInitialBackoff  = 30 * time.Second
+	outboxMaxBackoff      = 6 * time.Hour
+	outboxLastErrorLength = 1000
+	// sentOutboxPayload replaces the payload of delivered messages. Emails carry reset links and
+	// invite tokens, which must not stay readable in the database once they went out.
+	sentOutboxPayload = "{}"
+)
+
+type OutboxService struct {
+	repo *repositories.OutboxRepository
+}
+
+func NewOutboxService(db *gorm.DB) *OutboxService {
+	return &OutboxService{repo: repositories.NewOutboxRepository(db)}
+}
+
+// QueueEmail stores the email for delivery. Built on a transaction, the email is only sent
+// if that transaction commits.
+func (s *OutboxService) QueueEmail(message mailer.Message) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = s.repo.Create(&models.OutboxMessage{
+		Kind:          models.OutboxKindEmail,
+		Payload:       string(payload),
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	})
+	return err
+}
+
+// OutboxDispatcher delivers queued messages in the background, retrying failures with
+// exponential backoff.
+type OutboxDispatcher struct {
+	repo   *repositories.OutboxRepository
+	mailer mailer.Mailer
+}
+
+func NewOutboxDispatcher(db *gorm.DB, mail mailer.Mailer) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		repo:   repositories.NewOutboxRepository(db),
+		mailer: mail,
+	}
+}
+
+// Run dispatches due messages until the context is cancelled.
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.DispatchDue(); err != nil {
+			log.Printf("Failed to dispatch outbox messages: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue claims one batch of due messages, delivers them outside of any transaction and
+// records the outcome of each one on its own, so a failure to record one outcome does not
+// send the rest of the batch again.
+func (d *OutboxDispatcher) DispatchDue() error {
+	messages, err := d.repo.ClaimDue(time.Now(), outboxBatchSize, outboxLease)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		message := &messages[i]
+		d.attempt(message)
+		saved, err := d.repo.SaveAttempt(message)
+		if err != nil {
+			log.Printf("Failed to record the attempt of outbox message %s: %v", message.ID, err)
+		} else if !saved {
+			log.Printf("Outbox message %s was claimed again before its attempt was recorded", message.ID)
+		}
+	}
+	return nil
+}
+
+// attempt delivers a message claimed by ClaimDue, which already counted the attempt.
+func (d *OutboxDispatcher) attempt(message *models.OutboxMessage) {
+	err := d.deliver(message)
+	if err == nil {
+		now := time.Now()
+		message.Status = models.OutboxStatusSent
+		message.SentAt = &now
+		message.LastError = ""
+		message.Payload = sentOutboxPayload
+		return
+	}
+
+	message.LastError = err.Error()
+	if len(message.LastError) > outboxLastErrorLength {
+		message.LastError = message.LastError[:outboxLastErrorLength]
+	}
+	if message.Attempts >= outboxMaxAttempts {
+		message.Status = models.OutboxStatusDead
+		log.Printf("Outbox message %s is dead after %d attempts: %v", message.ID, message.Attempts, err)
+		return
+	}
+	message.NextAttemptAt = time.Now().Add(outboxBackoff(message.Attempts))
+	log.Printf("Outbox message %s failed, attempt %d: %v", message.ID, message.Attempts, err)
+}
+
+func (d *OutboxDispatcher) deliver(message *models.OutboxMessage) error {
+	switch message.Kind {
+	case models.OutboxKindEmail:
+		var email mailer.Message
+		if err := json.Unmarshal([]byte(message.Payload), &email); err != nil {
+			return err
+		}
+		return d.mailer.Send(email)
+	default:
+		return fmt.Errorf("unknown outbox message kind %q", message.Kind)
+	}
+}
+
+// outboxBackoff doubles the delay after every failed attempt, up to outboxMaxBackoff.
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxInitialBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxMaxBackoff)
+}
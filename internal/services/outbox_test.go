@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"fleet-pulse-users-service/internal/mailer"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"fleet-pulse-users-service/internal/testdb"
+	"testing"
+	"time"
+)
+
+// rejectingMailer fails every email to one recipient and hands the others on.
+type rejectingMailer struct {
+	*mailer.MemoryMailer
+	rejected string
+}
+
+func (m rejectingMailer) Send(message mailer.Message) error {
+	if message.To == m.rejected {
+		return errors.New("mailbox unavailable")
+	}
+	return m.MemoryMailer.Send(message)
+}
+
+func TestDispatchDueRecordsEachOutcome(t *testing.T) {
+	db := testdb.Open(t)
+	outbox := NewOutboxService(db)
+	for _, to := range []string{"ok@example.com", "bounce@example.com"} {
+		if err := outbox.QueueEmail(mailer.Message{To: to, Subject: "Hello"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mail := rejectingMailer{MemoryMailer: mailer.NewMemoryMailer(), rejected: "bounce@example.com"}
+
+	if err := NewOutboxDispatcher(db, mail).DispatchDue(); err != nil {
+		t.Fatal(err)
+	}
+	if sent := mail.Messages(); len(sent) != 1 || sent[0].To != "ok@example.com" {
+		t.Fatalf("got %v sent, want the email to ok@example.com", sent)
+	}
+
+	var messages []models.OutboxMessage
+	if err := db.Order("status").Find(&messages).Error; err != nil {
+		t.Fatal(err)
+	}
+	failed, sent := messages[0], messages[1]
+	if sent.Status != models.OutboxStatusSent || sent.SentAt == nil || sent.Attempts != 1 {
+		t.Fatalf("got status %s after %d attempts, want sent after 1", sent.Status, sent.Attempts)
+	}
+	if sent.Payload != sentOutboxPayload || failed.Payload == sentOutboxPayload {
+		t.Fatalf("got payloads %q and %q, want only the sent one cleared", sent.Payload, failed.Payload)
+	}
+	if failed.Status != models.OutboxStatusPending || failed.Attempts != 1 || failed.LastError == "" {
+		t.Fatalf("got status %s after %d attempts, want pending after 1 with the error", failed.Status, failed.Attempts)
+	}
+	if wait := time.Until(failed.NextAttemptAt); wait <= 0 || wait > outboxInitialBackoff {
+		t.Fatalf("got the next attempt in %s, want it after the initial backoff", wait)
+	}
+
+	// Nothing is due before the backoff ends.
+	if err := NewOutboxDispatcher(db, mail).DispatchDue(); err != nil {
+		t.Fatal(err)
+	}
+	if sent := mail.Messages(); len(sent) != 1 {
+		t.Fatalf("got %d emails sent, want the sent one not to go out again", len(sent))
+	}
+}
+
+func TestClaimDueLeasesMessages(t *testing.T) {
+	db := testdb.Open(t)
+	if err := NewOutboxService(db).QueueEmail(mailer.Message{To: "ok@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	repo := repositories.NewOutboxRepository(db)
+	now := time.Now()
+
+	claimed, err := repo.ClaimDue(now, outboxBatchSize, outboxLease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].Attempts != 1 {
+		t.Fatalf("got %d messages claimed, want the queued one with its attempt counted", len(claimed))
+	}
+	if again, err := repo.ClaimDue(now, outboxBatchSize, outboxLease); err != nil || len(again) != 0 {
+		t.Fatalf("got %d messages claimed during the lease (%v), want none", len(again), err)
+	}
+
+	// A dispatcher that died keeps the message only until the lease ends.
+	reclaimed, err := repo.ClaimDue(now.Add(outboxLease+time.Second), outboxBatchSize, outboxLease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reclaimed) != 1 || reclaimed[0].Attempts != 2 {
+		t.Fatalf("got %d messages claimed after the lease, want the queued one on its second attempt", len(reclaimed))
+	}
+	claimed[0].Status = models.OutboxStatusSent
+	if saved, err := repo.SaveAttempt(&claimed[0]); err != nil || saved {
+		t.Fatalf("outdated attempt: got saved %v (%v), want it ignored", saved, err)
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/mailer"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/passwords"
+	"fleet-pulse-users-service/internal/repositories"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PasswordService struct {
+	repo                   *repositories.PasswordResetTokenRepository
+	userRepo               *repositories.UserRepository
+	sessionRepo            *repositories.SessionRepository
+	refreshTokenRepository *repositories.RefreshTokenRepository
+	revokedTokenRepo       *repositories.RevokedAccessTokenRepository
+	outbox                 *OutboxService
+	securityEvents         *SecurityEventService
+}
+
+func NewPasswordService(db *gorm.DB) *PasswordService {
+	return &PasswordService{
+		repo:                   repositories.NewPasswordResetTokenRepository(db),
+		userRepo:               repositories.NewUserRepository(db),
+		sessionRepo:            repositories.NewSessionRepository(db),
+		refreshTokenRepository: repositories.NewRefreshTokenRepository(db),
+		revokedTokenRepo:       repositories.NewRevokedAccessTokenRepository(db),
+		outbox:                 NewOutboxService(db),
+		securityEvents:         NewSecurityEventService(db),
+	}
+}
+
+// ValidatePassword checks a password the user wants to set against the password policy.
+func ValidatePassword(password string, user *models.User) error {
+	return passwords.Get().Validate(password, passwords.PersonalInfo{
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+	})
+}
+
+// ForgotPassword emails a single use reset link to the user with the email. Unknown emails
+// are silently ignored, so callers cannot tell which emails have an account. So are invited
+// users who never set a password, a reset must not stand in for a revoked or expired invite.
+func (s *PasswordService) ForgotPassword(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil || user == nil || user.Password == "" {
+		return nil
+	}
+
+	if err = s.repo.DeleteUnusedForUser(user.ID); err != nil {
+		return err
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(time.Duration(config.Get().Auth.PasswordResetExpireInMinutes) * time.Minute)
+	if _, err = s.repo.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: HashRefreshToken(token),
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return err
+	}
+
+	message, err := mailer.RenderPasswordReset(user.Email, mailer.PasswordResetEmail{
+		FirstName: user.FirstName,
+		ResetURL:  PasswordResetURL(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	return s.outbox.QueueEmail(message)
+}
+
+// PasswordResetURL is the frontend page that lets the user choose a new password.
+func PasswordResetURL(token string) string {
+	return config.Get().Server.FrontendBaseURL + "/password/reset?token=" + url.QueryEscape(token)
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. The user is signed out
+// everywhere, since whoever knew the old password must not stay signed in.
+func (s *PasswordService) ResetPassword(tokenString, password string) error {
+	token, err := s.repo.GetByTokenHash(HashRefreshToken(tokenString))
+	if err != nil || token == nil {
+		return errors.ErrInvalidResetToken
+	}
+	consumed, err := s.repo.Consume(token, time.Now())
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return errors.ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.GetById(token.UserID)
+	if err != nil || user == nil || user.Password == "" {
+		return errors.ErrInvalidResetToken
+	}
+	if err = ValidatePassword(password, user); err != nil {
+		return err
+	}
+	password, err = HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if _, err = s.userRepo.Update(user, models.User{Password: password}); err != nil {
+		return err
+	}
+
+	if err = s.sessionRepo.DeleteUserSessions(user.ID); err != nil {
+		return err
+	}
+	s.refreshTokenRepository.DeletePreviousTokens(user.ID)
+	if err = TokenRevocations().RevokeUserTokens(s.revokedTokenRepo, user.ID); err != nil {
+		return err
+	}
+	s.securityEvents.Record(models.SecurityEventPasswordReset, user.ID, user.CompanyID, nil)
+	return nil
+}
+
+// ChangePassword replaces the password of a signed in user after checking the current one.
+// Every other session is signed out. The caller's session and its refresh token are kept, but
+// all access tokens issued so far, the caller's included, are rejected, so the caller has to
+// refresh to go on.
+func (s *PasswordService) ChangePassword(userID uuid.UUID, accessToken *Claims, currentPassword, newPassword string) error {
+	user, err := s.userRepo.GetById(userID)
+	if err != nil || user == nil {
+		return errors.ErrUserNotFound
+	}
+	if !CheckPassword(user.Password, currentPassword) {
+		return errors.ErrInvalidCredentials
+	}
+	if currentPassword == newPassword {
+		return errors.ErrPasswordUnchanged
+	}
+	if err = ValidatePassword(newPassword, user); err != nil {
+		return err
+	}
+	password, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if _, err = s.userRepo.Update(user, models.User{Password: password}); err != nil {
+		return err
+	}
+
+	if accessToken != nil && accessToken.SessionID != nil {
+		sessionID := *accessToken.SessionID
+		if err = s.sessionRepo.DeleteOtherUserSessions(user.ID, sessionID); err != nil {
+			return err
+		}
+		if err = s.refreshTokenRepository.DeleteOtherSessionTokens(user.ID, sessionID); err != nil {
+			return err
+		}
+	} else {
+		if err = s.sessionRepo.DeleteUserSessions(user.ID); err != nil {
+			return err
+		}
+		s.refreshTokenRepository.DeletePreviousTokens(user.ID)
+	}
+	if err = TokenRevocations().RevokeUserTokens(s.revokedTokenRepo, user.ID); err != nil {
+		return err
+	}
+	// The watermark spares tokens issued in its own second, the caller's may be one of them.
+	if accessToken != nil {
+		if err = TokenRevocations().RevokeToken(s.revokedTokenRepo, accessToken); err != nil {
+			return err
+		}
+	}
+	s.securityEvents.Record(models.SecurityEventPasswordChanged, user.ID, user.CompanyID, nil)
+	return nil
+}
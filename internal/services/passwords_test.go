@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/testdb"
+	"testing"
+	"time"
+)
+
+func TestPasswordResetTokenWorksOnce(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	passwordService := NewPasswordService(db)
+	user := createUser(t, db)
+	_, _, refreshToken := signIn(t, authService, user.Email)
+
+	if err := passwordService.ForgotPassword(user.Email); err != nil {
+		t.Fatal(err)
+	}
+	token := lastEmailedToken(t, db)
+	if err := passwordService.ResetPassword(token, "Another-Staple-Horse-4"); err != nil {
+		t.Fatal(err)
+	}
+	var stored models.User
+	if err := db.First(&stored, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(stored.Password, "Another-Staple-Horse-4") {
+		t.Fatal("the password was not changed")
+	}
+	if _, _, err := authService.RefreshAccessToken(refreshToken, ClientInfo{}); err == nil {
+		t.Fatal("the session from before the reset still works")
+	}
+
+	if err := passwordService.ResetPassword(token, testPassword); err != errors.ErrInvalidResetToken {
+		t.Fatalf("replayed token: got %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestForgotPasswordIgnoresUnknownEmails(t *testing.T) {
+	db := testdb.Open(t)
+	if err := NewPasswordService(db).ForgotPassword("nobody@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&models.OutboxMessage{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("got %d queued emails for an unknown email, want none", count)
+	}
+}
+
+func TestChangePasswordSignsOutOtherSessions(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	passwordService := NewPasswordService(db)
+	user := createUser(t, db)
+	_, claims, refreshToken := signIn(t, authService, user.Email)
+	_, _, otherRefreshToken := signIn(t, authService, user.Email)
+
+	if err := passwordService.ChangePassword(user.ID, claims, "wrong password", "Another-Staple-Horse-4"); err != errors.ErrInvalidCredentials {
+		t.Fatalf("wrong current password: got %v, want ErrInvalidCredentials", err)
+	}
+	if err := passwordService.ChangePassword(user.ID, claims, testPassword, "Another-Staple-Horse-4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := authService.RefreshAccessToken(otherRefreshToken, ClientInfo{}); err == nil {
+		t.Fatal("another session still works after the change")
+	}
+	if _, _, err := authService.RefreshAccessToken(refreshToken, ClientInfo{}); err != nil {
+		t.Fatalf("session of the change: got %v, want it kept", err)
+	}
+}
+
+func TestPasswordResetRefusedForInvitedUsers(t *testing.T) {
+	db := testdb.Open(t)
+	passwordService := NewPasswordService(db)
+	invited := &models.User{FirstName: "Ivy", LastName: "Invitee", Email: "ivy@example.com"}
+	if err := db.Create(invited).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := passwordService.ForgotPassword(invited.Email); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&models.PasswordResetToken{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("got %d reset tokens for an invited user, want none", count)
+	}
+	db.Model(&models.OutboxMessage{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("got %d queued emails for an invited user, want none", count)
+	}
+
+	// A token issued before the check existed must not work either.
+	token := "stale-reset-token"
+	if err := db.Create(&models.PasswordResetToken{
+		UserID:    invited.ID,
+		TokenHash: HashRefreshToken(token),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := passwordService.ResetPassword(token, testPassword); err != errors.ErrInvalidResetToken {
+		t.Fatalf("got %v, want ErrInvalidResetToken", err)
+	}
+}
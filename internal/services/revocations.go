@@ -0,0 +1,196 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Other replicas pick up a revocation at most this long after it happened.
+const revocationRefreshInterval = 15 * time.Second
+
+// RevocationList rejects access tokens before they expire, one by one through their jti, all
+// tokens of a signed out session, or all tokens issued to a user before a watermark. It is backed by the database so that all
+// replicas share it, and cached in memory so that checking a token costs no query.
+type RevocationList struct {
+	repo       *repositories.RevokedAccessTokenRepository
+	reloadMu   sync.Mutex
+	mu         sync.RWMutex
+	tokens     map[string]time.Time
+	sessions   map[uuid.UUID]time.Time
+	watermarks map[uuid.UUID]time.Time
+	loadedAt   time.Time
+}
+
+var tokenRevocations *RevocationList
+
+// InitTokenRevocations loads the process-wide revocation list.
+func InitTokenRevocations(db *gorm.DB) (*RevocationList, error) {
+	list := NewRevocationList(db)
+	if err := list.Reload(); err != nil {
+		return nil, err
+	}
+	tokenRevocations = list
+	return list, nil
+}
+
+// TokenRevocations returns the revocation list loaded by InitTokenRevocations.
+func TokenRevocations() *RevocationList {
+	if tokenRevocations == nil {
+		log.Fatal("Token revocations are not initialized")
+	}
+	return tokenRevocations
+}
+
+func NewRevocationList(db *gorm.DB) *RevocationList {
+	return &RevocationList{
+		repo:       repositories.NewRevokedAccessTokenRepository(db),
+		tokens:     map[string]time.Time{},
+		sessions:   map[uuid.UUID]time.Time{},
+		watermarks: map[uuid.UUID]time.Time{},
+	}
+}
+
+// IsRevoked reports whether the access token was revoked. Watermarks are whole seconds like
+// iat, so tokens issued in the second of a watermark are accepted.
+func (r *RevocationList) IsRevoked(claims *Claims) bool {
+	r.refreshIfStale()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.tokens[claims.ID]; ok && claims.ID != "" {
+		return true
+	}
+	if claims.SessionID != nil {
+		if _, ok := r.sessions[*claims.SessionID]; ok {
+			return true
+		}
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return false
+	}
+	watermark, ok := r.watermarks[userID]
+	return ok && (claims.IssuedAt == nil || claims.IssuedAt.Before(watermark))
+}
+
+// RevokeToken rejects a single access token until it expires. The revocation is written
+// through repo so that it commits or rolls back with the caller's transaction.
+func (r *RevocationList) RevokeToken(repo *repositories.RevokedAccessTokenRepository, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	token := &models.RevokedAccessToken{JTI: claims.ID, ExpiresAt: claims.ExpiresAt.Time}
+	if userID, err := uuid.Parse(claims.UserID); err == nil {
+		token.UserID = &userID
+	}
+	if err := repo.Revoke(token); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.JTI] = token.ExpiresAt
+	return nil
+}
+
+// RevokeSessionTokens rejects every access token issued to the session. The revocation is
+// written through repo so that it commits or rolls back with the caller's transaction.
+func (r *RevocationList) RevokeSessionTokens(repo *repositories.RevokedAccessTokenRepository, session *models.Session) error {
+	// Tokens issued to the session from now on are refused anyway, since the session is gone.
+	accessTokenLifetime := time.Duration(config.Get().Auth.JwtAccessTokenExpireInMinutes) * time.Minute
+	revoked := &models.RevokedSession{
+		SessionID: session.ID,
+		UserID:    &session.UserID,
+		ExpiresAt: time.Now().Add(accessTokenLifetime),
+	}
+	if err := repo.RevokeSession(revoked); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[revoked.SessionID] = revoked.ExpiresAt
+	return nil
+}
+
+// RevokeUserTokens rejects every access token issued to the user before the current second.
+// iat has no finer precision, and a token refreshed right after the revocation must be
+// accepted, so tokens issued earlier in the same second pass as well; callers revoke the token
+// of the request by its jti where that matters. The watermark is written through repo so that
+// it commits or rolls back with the caller's transaction; a rolled back watermark lingers in
+// this replica's cache until the next reload.
+func (r *RevocationList) RevokeUserTokens(repo *repositories.RevokedAccessTokenRepository, userID uuid.UUID) error {
+	now := time.Now().Truncate(time.Second)
+	if err := repo.SetTokenWatermark(userID, now); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watermarks[userID] = now
+	return nil
+}
+
+// Reload replaces the cache with the revocations currently stored in the database.
+func (r *RevocationList) Reload() error {
+	now := time.Now()
+	if err := r.repo.DeleteExpired(now); err != nil {
+		return err
+	}
+	rows, err := r.repo.ListUnexpired(now)
+	if err != nil {
+		return err
+	}
+	sessionRows, err := r.repo.ListUnexpiredSessions(now)
+	if err != nil {
+		return err
+	}
+	// Older watermarks can only reject tokens that have expired on their own.
+	accessTokenLifetime := time.Duration(config.Get().Auth.JwtAccessTokenExpireInMinutes) * time.Minute
+	userWatermarks, err := r.repo.ListTokenWatermarks(now.Add(-accessTokenLifetime))
+	if err != nil {
+		return err
+	}
+
+	tokens := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		tokens[row.JTI] = row.ExpiresAt
+	}
+	sessions := make(map[uuid.UUID]time.Time, len(sessionRows))
+	for _, row := range sessionRows {
+		sessions[row.SessionID] = row.ExpiresAt
+	}
+	watermarks := make(map[uuid.UUID]time.Time, len(userWatermarks))
+	for _, watermark := range userWatermarks {
+		watermarks[watermark.ID] = watermark.TokensValidAfter
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = tokens
+	r.sessions = sessions
+	r.watermarks = watermarks
+	r.loadedAt = now
+	return nil
+}
+
+func (r *RevocationList) refreshIfStale() {
+	r.mu.RLock()
+	stale := time.Since(r.loadedAt) > revocationRefreshInterval
+	r.mu.RUnlock()
+	// Requests arriving while another one reloads are checked against the current cache.
+	if !stale || !r.reloadMu.TryLock() {
+		return
+	}
+	defer r.reloadMu.Unlock()
+	if err := r.Reload(); err != nil {
+		log.Printf("Failed to reload token revocations: %v", err)
+	}
+}
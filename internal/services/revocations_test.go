@@ -0,0 +1,186 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"fleet-pulse-users-service/internal/schemas"
+	"fleet-pulse-users-service/internal/testdb"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signIn returns the access token, its claims and the refresh token of a password sign-in.
+func signIn(t *testing.T, authService *AuthService, email string) (string, *Claims, string) {
+	t.Helper()
+	accessToken, refreshToken, _, err := authService.LoginUser(schemas.LoginUserRequest{Email: email, Password: testPassword}, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := authService.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return accessToken, claims, refreshToken
+}
+
+func TestRevokeTokenRejectsOnlyThatToken(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	revokedToken, revokedClaims, _ := signIn(t, authService, user.Email)
+	otherToken, _, _ := signIn(t, authService, user.Email)
+
+	if err := authService.RevokeAccessToken(revokedClaims); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authService.ValidateAccessToken(revokedToken); err != errors.ErrInvalidToken {
+		t.Fatalf("revoked token: got %v, want ErrInvalidToken", err)
+	}
+	if _, err := authService.ValidateAccessToken(otherToken); err != nil {
+		t.Fatalf("other token: got %v, want it accepted", err)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	accessToken, _, refreshToken := signIn(t, authService, user.Email)
+
+	if err := authService.Logout(refreshToken, accessToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authService.ValidateAccessToken(accessToken); err != errors.ErrInvalidToken {
+		t.Fatalf("access token after logout: got %v, want ErrInvalidToken", err)
+	}
+	if _, _, err := authService.RefreshAccessToken(refreshToken, ClientInfo{}); err == nil {
+		t.Fatal("the refresh token still works after logout")
+	}
+}
+
+func TestRevokeSessionRejectsItsAccessTokens(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	revokedToken, revokedClaims, refreshToken := signIn(t, authService, user.Email)
+	otherToken, _, _ := signIn(t, authService, user.Email)
+
+	if err := NewSessionService(db).RevokeSession(user.ID, *revokedClaims.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authService.ValidateAccessToken(revokedToken); err != errors.ErrInvalidToken {
+		t.Fatalf("access token of the revoked session: got %v, want ErrInvalidToken", err)
+	}
+	if _, _, err := authService.RefreshAccessToken(refreshToken, ClientInfo{}); err == nil {
+		t.Fatal("the refresh token of the revoked session still works")
+	}
+	if _, err := authService.ValidateAccessToken(otherToken); err != nil {
+		t.Fatalf("access token of another session: got %v, want it accepted", err)
+	}
+
+	replica := NewRevocationList(db)
+	if err := replica.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !replica.IsRevoked(revokedClaims) {
+		t.Fatal("another replica accepts the access token of the revoked session")
+	}
+}
+
+func TestRevocationsReachOtherReplicas(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	other := &models.User{FirstName: "Olga", LastName: "Other", Email: "olga@example.com", Password: user.Password}
+	if err := db.Create(other).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, claims, _ := signIn(t, authService, user.Email)
+	otherAccessToken, otherClaims, _ := signIn(t, authService, other.Email)
+
+	if err := authService.RevokeAccessToken(claims); err != nil {
+		t.Fatal(err)
+	}
+	// A watermark set by another replica is only known from the database.
+	repo := repositories.NewRevokedAccessTokenRepository(db)
+	if err := repo.SetTokenWatermark(other.ID, time.Now().Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authService.ValidateAccessToken(otherAccessToken); err != nil {
+		t.Fatalf("before the reload: got %v, want the cached list to accept the token", err)
+	}
+
+	replica := NewRevocationList(db)
+	if err := replica.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !replica.IsRevoked(claims) {
+		t.Fatal("the revoked jti did not reach the other replica")
+	}
+	if !replica.IsRevoked(otherClaims) {
+		t.Fatal("the watermark did not reach the other replica")
+	}
+}
+
+func TestReloadForgetsExpiredRevocations(t *testing.T) {
+	db := testdb.Open(t)
+	newTokenAuthService(t, db)
+	expired := &models.RevokedAccessToken{JTI: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := db.Create(expired).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TokenRevocations().Reload(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&models.RevokedAccessToken{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("got %d revoked tokens stored, want the expired one deleted", count)
+	}
+}
+
+func TestWatermarkAcceptsTokensFromItsOwnSecond(t *testing.T) {
+	db := testdb.Open(t)
+	newTokenAuthService(t, db)
+	user := createUser(t, db)
+
+	if err := TokenRevocations().RevokeUserTokens(repositories.NewRevokedAccessTokenRepository(db), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	issuedNow := &Claims{UserID: user.ID.String(), RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(now)}}
+	if TokenRevocations().IsRevoked(issuedNow) {
+		t.Fatal("a token issued in the second of the watermark was rejected")
+	}
+	issuedBefore := &Claims{UserID: user.ID.String(), RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(now.Add(-2 * time.Second))}}
+	if !TokenRevocations().IsRevoked(issuedBefore) {
+		t.Fatal("a token issued before the watermark was accepted")
+	}
+}
+
+func TestChangePasswordKeepsCallerSignedIn(t *testing.T) {
+	db := testdb.Open(t)
+	authService := newTokenAuthService(t, db)
+	user := createUser(t, db)
+	accessToken, claims, refreshToken := signIn(t, authService, user.Email)
+
+	if err := NewPasswordService(db).ChangePassword(user.ID, claims, testPassword, "Another-Staple-Horse-4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authService.ValidateAccessToken(accessToken); err != errors.ErrInvalidToken {
+		t.Fatalf("access token from before the change: got %v, want ErrInvalidToken", err)
+	}
+
+	// The refreshed token is most likely issued in the same second as the watermark.
+	refreshedToken, _, err := authService.RefreshAccessToken(refreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = authService.ValidateAccessToken(refreshedToken); err != nil {
+		t.Fatalf("refreshed access token: got %v, want it accepted", err)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RoleService struct {
+	repo             *repositories.RoleRepository
+	userRepo         *repositories.UserRepository
+	revokedTokenRepo *repositories.RevokedAccessTokenRepository
+}
+
+func NewRoleService(db *gorm.DB) *RoleService {
+	roleRepo := repositories.NewRoleRepository(db)
+	userRepo := repositories.NewUserRepository(db)
+	return &RoleService{
+		repo:             roleRepo,
+		userRepo:         userRepo,
+		revokedTokenRepo: repositories.NewRevokedAccessTokenRepository(db),
+	}
+}
+
+func (s *RoleService) ListRoles() ([]models.Role, error) {
+	return s.repo.List()
+}
+
+func (s *RoleService) GetUserRoles(userID uuid.UUID) ([]models.Role, error) {
+	user, err := s.userRepo.GetById(userID)
+	if err != nil || user == nil {
+		return nil, errors.ErrUserNotFound
+	}
+	return s.repo.GetUserRoles(user.ID)
+}
+
+// SetUserRoles replaces the roles of a user of the caller's company. Access tokens carrying
+// the previous permissions are rejected, so the user has to refresh to pick up the new ones.
+func (s *RoleService) SetUserRoles(userID uuid.UUID, roleNames []string) ([]models.Role, error) {
+	user, err := s.userRepo.GetById(userID)
+	if err != nil || user == nil {
+		return nil, errors.ErrUserNotFound
+	}
+
+	roles := []models.Role{}
+	if len(roleNames) > 0 {
+		roles, err = s.repo.GetByNames(roleNames)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(roles) != len(uniqueStrings(roleNames)) {
+		return nil, errors.ErrRoleNotFound
+	}
+
+	if err = s.repo.SetUserRoles(user, roles); err != nil {
+		return nil, err
+	}
+	if err = TokenRevocations().RevokeUserTokens(s.revokedTokenRepo, user.ID); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		unique = append(unique, value)
+	}
+	return unique
+}
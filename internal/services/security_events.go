@@ -0,0 +1,43 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"log"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SecurityEventService struct {
+	repo *repositories.SecurityEventRepository
+}
+
+func NewSecurityEventService(db *gorm.DB) *SecurityEventService {
+	return &SecurityEventService{repo: repositories.NewSecurityEventRepository(db)}
+}
+
+// Record logs the event and stores it. Failing to store it never fails the caller,
+// the log line is kept as a fallback.
+func (s *SecurityEventService) Record(
+	eventType string,
+	userID uuid.UUID,
+	companyID *uuid.UUID,
+	details map[string]interface{},
+) {
+	company := "none"
+	if companyID != nil {
+		company = companyID.String()
+	}
+	log.Printf("Security event %s: user=%s company=%s details=%v", eventType, userID, company, details)
+
+	_, err := s.repo.Create(&models.SecurityEvent{
+		Type:      eventType,
+		UserID:    &userID,
+		CompanyID: companyID,
+		Details:   details,
+	})
+	if err != nil {
+		log.Printf("Failed to store security event %s: %v", eventType, err)
+	}
+}
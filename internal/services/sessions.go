@@ -0,0 +1,40 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SessionService struct {
+	repo             *repositories.SessionRepository
+	revokedTokenRepo *repositories.RevokedAccessTokenRepository
+}
+
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{
+		repo:             repositories.NewSessionRepository(db),
+		revokedTokenRepo: repositories.NewRevokedAccessTokenRepository(db),
+	}
+}
+
+func (s *SessionService) ListSessions(userID uuid.UUID) ([]models.Session, error) {
+	return s.repo.ListActive(userID, time.Now())
+}
+
+// RevokeSession signs the device out. Its refresh tokens are removed together with it and
+// the access tokens already issued to it are rejected.
+func (s *SessionService) RevokeSession(userID, sessionID uuid.UUID) error {
+	session, err := s.repo.GetUserSession(userID, sessionID)
+	if err != nil || session == nil {
+		return errors.ErrSessionNotFound
+	}
+	if err = TokenRevocations().RevokeSessionTokens(s.revokedTokenRepo, session); err != nil {
+		return err
+	}
+	return s.repo.DeleteObj(session)
+}
@@ -6,29 +6,29 @@ import (
 	"fleet-pulse-users-service/internal/models"
 	"fleet-pulse-users-service/internal/repositories"
 	"fleet-pulse-users-service/internal/schemas"
-	"fmt"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-var inviteSecret = []byte(config.Get().Auth.InviteSecret)
-
-type InviteClaims struct {
-	UserID    uuid.UUID `json:"user_id"`
-	CompanyID uuid.UUID `json:"company_id"`
-	jwt.RegisteredClaims
-}
-
 type UserService struct {
-	repo *repositories.UserRepository
+	repo                   *repositories.UserRepository
+	sessionRepo            *repositories.SessionRepository
+	refreshTokenRepository *repositories.RefreshTokenRepository
+	revokedTokenRepo       *repositories.RevokedAccessTokenRepository
+	invites                *InviteService
 }
 
 func NewUserService(db *gorm.DB) *UserService {
 	userRepo := repositories.NewUserRepository(db)
-	return &UserService{repo: userRepo}
+	return &UserService{
+		repo:                   userRepo,
+		sessionRepo:            repositories.NewSessionRepository(db),
+		refreshTokenRepository: repositories.NewRefreshTokenRepository(db),
+		revokedTokenRepo:       repositories.NewRevokedAccessTokenRepository(db),
+		invites:                NewInviteService(db),
+	}
 }
 
 func (s *UserService) GetUserById(id uuid.UUID) (*models.User, error) {
@@ -39,73 +39,69 @@ func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
 	return s.repo.GetUserByEmail(email)
 }
 
+// RegisterNewUser signs up a user that does not belong to any company yet. Company users are
+// invited by their fleet admin instead, see InviteService.Invite.
 func (s *UserService) RegisterNewUser(data schemas.CreateUserRequest) (*models.User, error) {
-	existingUser, _ := s.repo.GetUserByEmail(data.Email)
-	if existingUser != nil {
-		return nil, errors.ErrEmailAlreadyExists
+	if !config.Get().Auth.SelfSignupEnabled {
+		return nil, errors.ErrSelfSignupDisabled
+	}
+	exists, err := s.repo.EmailExists(data.Email)
+	if err != nil {
+		return nil, err
 	}
-	var hashPassword string
-	var err error
-	if data.Password != "" {
-		hashPassword, err = HashPassword(data.Password)
-		if err != nil {
-			return nil, err
-		}
+	if exists {
+		return nil, errors.ErrEmailAlreadyExists
 	}
-	user, err := s.repo.Create(&models.User{
+	user := &models.User{
 		FirstName: data.FirstName,
 		LastName:  data.LastName,
 		Email:     data.Email,
-		Password:  hashPassword,
-	})
-	if err != nil {
+	}
+	if err = ValidatePassword(data.Password, user); err != nil {
 		return nil, err
 	}
-	err = s.SendInvite(user.ID)
+	user.Password, err = HashPassword(data.Password)
 	if err != nil {
 		return nil, err
 	}
-	return user, nil
-}
-
-func (s *UserService) SendInvite(userID uuid.UUID) error {
-	claims := InviteClaims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(72 * time.Hour)),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secret, err := token.SignedString(inviteSecret)
-	fmt.Println(secret)
-	return err
+	return s.repo.Create(user)
 }
 
+// AcceptInvite sets the password of the invited user. Revoked, expired and already used invites are rejected.
 func (s *UserService) AcceptInvite(tokenString, password string) (*models.User, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &InviteClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return inviteSecret, nil
-	})
+	user, err := s.invites.Accept(tokenString)
 	if err != nil {
-		return nil, errors.ErrInvalidInviteToken
-	}
-
-	claims, ok := token.Claims.(*InviteClaims)
-	if !ok || !token.Valid {
-		return nil, errors.ErrInvalidInviteToken
+		return nil, err
 	}
-
-	user, err := s.GetUserById(claims.UserID)
-	if user == nil || err != nil {
-		return nil, errors.ErrUserNotFound
+	if err = ValidatePassword(password, user); err != nil {
+		return nil, err
 	}
 	password, err = HashPassword(password)
 	if err != nil {
 		return nil, err
 	}
-	user, err = s.repo.Update(user, models.User{Password: password})
+	acceptedAt := time.Now()
+	user, err = s.repo.Update(user, models.User{Password: password, AcceptedAt: &acceptedAt})
 	if err != nil {
 		return nil, err
 	}
+	// Tokens obtained with the previous password must not outlive it.
+	if err = TokenRevocations().RevokeUserTokens(s.revokedTokenRepo, user.ID); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
+
+// RevokeUserTokens signs a user of the caller's company out everywhere. Sessions and refresh
+// tokens are deleted and access tokens already issued are rejected right away.
+func (s *UserService) RevokeUserTokens(userID uuid.UUID) error {
+	user, err := s.repo.GetById(userID)
+	if err != nil || user == nil {
+		return errors.ErrUserNotFound
+	}
+	if err = s.sessionRepo.DeleteUserSessions(user.ID); err != nil {
+		return err
+	}
+	s.refreshTokenRepository.DeletePreviousTokens(user.ID)
+	return TokenRevocations().RevokeUserTokens(s.revokedTokenRepo, user.ID)
+}
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/repositories"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	webAuthnCeremonyLifetime = 5 * time.Minute
+	defaultPasskeyName       = "Passkey"
+)
+
+var (
+	relyingParty     *webauthn.WebAuthn
+	relyingPartyOnce sync.Once
+)
+
+// RelyingParty returns the WebAuthn relying party configured from the environment.
+func RelyingParty() *webauthn.WebAuthn {
+	relyingPartyOnce.Do(func() {
+		rp, err := NewRelyingParty(config.Get().Auth)
+		if err != nil {
+			log.Fatalf("Invalid WebAuthn configuration: %v", err)
+		}
+		relyingParty = rp
+	})
+	return relyingParty
+}
+
+// NewRelyingParty builds a relying party that asks authenticators to verify the user, e.g.
+// with a fingerprint or PIN, and to keep the passkey so it can be picked without an email.
+func NewRelyingParty(settings config.AuthConfig) (*webauthn.WebAuthn, error) {
+	ceremonyTimeout := webauthn.TimeoutConfig{
+		Enforce:    true,
+		Timeout:    webAuthnCeremonyLifetime,
+		TimeoutUVD: webAuthnCeremonyLifetime,
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          settings.WebAuthnRPID,
+		RPDisplayName: settings.WebAuthnRPName,
+		RPOrigins:     settings.WebAuthnRPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        ceremonyTimeout,
+			Registration: ceremonyTimeout,
+		},
+	})
+}
+
+// PasskeyCeremony is handed to the browser to run navigator.credentials.create or
+// navigator.credentials.get with Options. SessionID is sent back with the authenticator's response.
+type PasskeyCeremony struct {
+	SessionID uuid.UUID
+	Options   interface{}
+	ExpiresAt time.Time
+}
+
+type WebAuthnService struct {
+	credentialRepo *repositories.WebAuthnCredentialRepository
+	sessionRepo    *repositories.WebAuthnSessionRepository
+	userRepo       *repositories.UserRepository
+	mfa            *MFAService
+	securityEvents *SecurityEventService
+}
+
+func NewWebAuthnService(db *gorm.DB) *WebAuthnService {
+	return &WebAuthnService{
+		credentialRepo: repositories.NewWebAuthnCredentialRepository(db),
+		sessionRepo:    repositories.NewWebAuthnSessionRepository(db),
+		userRepo:       repositories.NewUserRepository(db),
+		mfa:            NewMFAService(db),
+		securityEvents: NewSecurityEventService(db),
+	}
+}
+
+func (s *WebAuthnService) ListCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	return s.credentialRepo.ListByUser(userID)
+}
+
+func (s *WebAuthnService) DeleteCredential(userID, id uuid.UUID) error {
+	credential, err := s.credentialRepo.GetUserCredential(userID, id)
+	if err != nil || credential == nil {
+		return errors.ErrPasskeyNotFound
+	}
+	if err = s.credentialRepo.DeleteObj(credential); err != nil {
+		return err
+	}
+	s.recordEvent(models.SecurityEventPasskeyRemoved, userID, credential)
+	return nil
+}
+
+// BeginRegistration starts adding a passkey for the user. A passkey signs in on its own, so the
+// user has to confirm the current password and, with two-factor authentication, a code first;
+// a stolen access token alone must not be enough. Authenticators that already hold one of the
+// user's passkeys are excluded.
+func (s *WebAuthnService) BeginRegistration(userID uuid.UUID, currentPassword, mfaCode string) (*PasskeyCeremony, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !CheckPassword(user.Password, currentPassword) {
+		return nil, errors.ErrInvalidCredentials
+	}
+	if err = s.mfa.RequireSecondFactor(user.ID, mfaCode); err != nil {
+		return nil, err
+	}
+	credentials, err := s.credentialRepo.ListByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	passkeyUser := newPasskeyUser(user, credentials)
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range passkeyUser.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	creation, sessionData, err := RelyingParty().BeginRegistration(passkeyUser, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, err
+	}
+	return s.saveCeremony(models.WebAuthnCeremonyRegistration, &user.ID, "", sessionData, creation)
+}
+
+// FinishRegistration verifies the authenticator's response to BeginRegistration and stores
+// the new passkey.
+func (s *WebAuthnService) FinishRegistration(
+	userID, sessionID uuid.UUID,
+	name string,
+	response []byte,
+) (*models.WebAuthnCredential, error) {
+	session, sessionData, err := s.consumeCeremony(sessionID, models.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID == nil || *session.UserID != userID {
+		return nil, errors.ErrInvalidPasskeySession
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, invalidPasskeyCredential(err)
+	}
+
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	created, err := RelyingParty().CreateCredential(newPasskeyUser(user, nil), *sessionData, parsed)
+	if err != nil {
+		return nil, invalidPasskeyCredential(err)
+	}
+	if existing, err := s.credentialRepo.GetByCredentialID(created.ID); err == nil && existing != nil {
+		return nil, errors.ErrPasskeyAlreadyRegistered
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	transports := make([]string, 0, len(created.Transport))
+	for _, transport := range created.Transport {
+		transports = append(transports, string(transport))
+	}
+	credential, err := s.credentialRepo.Create(&models.WebAuthnCredential{
+		UserID:          user.ID,
+		CredentialID:    created.ID,
+		PublicKey:       created.PublicKey,
+		AttestationType: created.AttestationType,
+		Transports:      strings.Join(transports, " "),
+		AAGUID:          created.Authenticator.AAGUID,
+		SignCount:       int64(created.Authenticator.SignCount),
+		BackupEligible:  created.Flags.BackupEligible,
+		BackupState:     created.Flags.BackupState,
+		Name:            name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.recordEvent(models.SecurityEventPasskeyRegistered, user.ID, credential)
+	return credential, nil
+}
+
+// BeginLogin starts a passwordless sign-in. With the email of a user who has passkeys the
+// authenticator is told which ones to use, otherwise it offers the passkeys it keeps for this
+// service, so an unknown email is not revealed.
+func (s *WebAuthnService) BeginLogin(email, deviceName string) (*PasskeyCeremony, error) {
+	if email != "" {
+		user, err := s.userRepo.GetUserByEmail(email)
+		if err == nil && user != nil {
+			credentials, err := s.credentialRepo.ListByUser(user.ID)
+			if err != nil {
+				return nil, err
+			}
+			if len(credentials) > 0 {
+				assertion, sessionData, err := RelyingParty().BeginLogin(newPasskeyUser(user, credentials))
+				if err != nil {
+					return nil, err
+				}
+				return s.saveCeremony(models.WebAuthnCeremonyLogin, &user.ID, deviceName, sessionData, assertion)
+			}
+		}
+	}
+
+	assertion, sessionData, err := RelyingParty().BeginDiscoverableLogin()
+	if err != nil {
+		return nil, err
+	}
+	return s.saveCeremony(models.WebAuthnCeremonyLogin, nil, deviceName, sessionData, assertion)
+}
+
+// FinishLogin verifies the authenticator's response to BeginLogin and returns the signed-in
+// user together with the device name given when the sign-in started. A signature counter that
+// went backwards means the passkey was likely copied, the passkey is then flagged and refused.
+func (s *WebAuthnService) FinishLogin(sessionID uuid.UUID, response []byte) (*models.User, string, error) {
+	session, sessionData, err := s.consumeCeremony(sessionID, models.WebAuthnCeremonyLogin)
+	if err != nil {
+		return nil, "", err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, "", invalidPasskeyCredential(err)
+	}
+
+	credential, err := s.credentialRepo.GetByCredentialID(parsed.RawID)
+	if err != nil || credential == nil {
+		return nil, "", errors.ErrInvalidPasskeyCredential
+	}
+	if session.UserID != nil && *session.UserID != credential.UserID {
+		return nil, "", errors.ErrInvalidPasskeyCredential
+	}
+	if credential.CloneWarning {
+		return nil, "", errors.ErrPasskeyCloneWarning
+	}
+	user, err := s.userRepo.GetById(credential.UserID)
+	if err != nil || user == nil {
+		return nil, "", errors.ErrInvalidPasskeyCredential
+	}
+	passkeyUser := newPasskeyUser(user, []models.WebAuthnCredential{*credential})
+
+	var validated *webauthn.Credential
+	if session.UserID != nil {
+		validated, err = RelyingParty().ValidateLogin(passkeyUser, *sessionData, parsed)
+	} else {
+		validated, err = RelyingParty().ValidateDiscoverableLogin(
+			func(_, userHandle []byte) (webauthn.User, error) {
+				if !bytes.Equal(userHandle, passkeyUser.WebAuthnID()) {
+					return nil, errors.ErrInvalidPasskeyCredential
+				}
+				return passkeyUser, nil
+			},
+			*sessionData,
+			parsed,
+		)
+	}
+	if err != nil {
+		return nil, "", invalidPasskeyCredential(err)
+	}
+
+	if validated.Authenticator.CloneWarning {
+		if err = s.credentialRepo.FlagClone(credential); err != nil {
+			return nil, "", err
+		}
+		s.securityEvents.Record(models.SecurityEventPasskeyCloneWarning, user.ID, user.CompanyID, map[string]interface{}{
+			"passkey_id":           credential.ID,
+			"stored_sign_count":    credential.SignCount,
+			"presented_sign_count": parsed.Response.AuthenticatorData.Counter,
+		})
+		return nil, "", errors.ErrPasskeyCloneWarning
+	}
+	err = s.credentialRepo.RecordLogin(credential, int64(validated.Authenticator.SignCount), validated.Flags.BackupState, time.Now())
+	if err != nil {
+		return nil, "", err
+	}
+	return user, session.DeviceName, nil
+}
+
+func (s *WebAuthnService) getUser(userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetById(userID)
+	if err != nil || user == nil {
+		return nil, errors.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *WebAuthnService) saveCeremony(
+	ceremony string,
+	userID *uuid.UUID,
+	deviceName string,
+	sessionData *webauthn.SessionData,
+	options interface{},
+) (*PasskeyCeremony, error) {
+	now := time.Now()
+	if err := s.sessionRepo.DeleteExpired(now); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(sessionData)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := now.Add(webAuthnCeremonyLifetime)
+	session, err := s.sessionRepo.Create(&models.WebAuthnSession{
+		UserID:     userID,
+		Ceremony:   ceremony,
+		Data:       data,
+		DeviceName: deviceName,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PasskeyCeremony{SessionID: session.ID, Options: options, ExpiresAt: expiresAt}, nil
+}
+
+func (s *WebAuthnService) consumeCeremony(
+	sessionID uuid.UUID,
+	ceremony string,
+) (*models.WebAuthnSession, *webauthn.SessionData, error) {
+	session, err := s.sessionRepo.Consume(sessionID, ceremony, time.Now())
+	if err != nil || session == nil {
+		return nil, nil, errors.ErrInvalidPasskeySession
+	}
+	var sessionData webauthn.SessionData
+	if err = json.Unmarshal(session.Data, &sessionData); err != nil {
+		return nil, nil, err
+	}
+	return session, &sessionData, nil
+}
+
+func (s *WebAuthnService) recordEvent(eventType string, userID uuid.UUID, credential *models.WebAuthnCredential) {
+	var companyID *uuid.UUID
+	if user, err := s.userRepo.GetById(userID); err == nil && user != nil {
+		companyID = user.CompanyID
+	}
+	s.securityEvents.Record(eventType, userID, companyID, map[string]interface{}{
+		"passkey_id": credential.ID,
+		"name":       credential.Name,
+	})
+}
+
+// invalidPasskeyCredential keeps the reason the WebAuthn library gives for rejecting a response.
+func invalidPasskeyCredential(err error) error {
+	return fmt.Errorf("%w: %s", errors.ErrInvalidPasskeyCredential, err.Error())
+}
+
+// passkeyUser presents a user and their passkeys to the WebAuthn library. The user ID is the
+// user handle authenticators store with a passkey.
+type passkeyUser struct {
+	user        *models.User
+	credentials []models.WebAuthnCredential
+}
+
+func newPasskeyUser(user *models.User, credentials []models.WebAuthnCredential) passkeyUser {
+	return passkeyUser{user: user, credentials: credentials}
+}
+
+func (u passkeyUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u passkeyUser) WebAuthnDisplayName() string {
+	if name := strings.TrimSpace(u.user.FirstName + " " + u.user.LastName); name != "" {
+		return name
+	}
+	return u.user.Email
+}
+
+func (u passkeyUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, stored := range u.credentials {
+		var transports []protocol.AuthenticatorTransport
+		for _, transport := range strings.Fields(stored.Transports) {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              stored.CredentialID,
+			PublicKey:       stored.PublicKey,
+			AttestationType: stored.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: stored.BackupEligible,
+				BackupState:    stored.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:       stored.AAGUID,
+				SignCount:    uint32(stored.SignCount),
+				CloneWarning: stored.CloneWarning,
+			},
+		})
+	}
+	return credentials
+}
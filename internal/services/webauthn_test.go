@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fleet-pulse-users-service/internal/config"
+	"fleet-pulse-users-service/internal/errors"
+	"fleet-pulse-users-service/internal/models"
+	"fleet-pulse-users-service/internal/softauthn"
+	"fleet-pulse-users-service/internal/testdb"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+func passkeyOrigin() string {
+	return config.Get().Auth.WebAuthnRPOrigins[0]
+}
+
+func registerPasskey(t *testing.T, service *WebAuthnService, user *models.User, authenticator *softauthn.Authenticator) *models.WebAuthnCredential {
+	t.Helper()
+	ceremony, err := service.BeginRegistration(user.ID, testPassword, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := authenticator.CreateCredential(ceremony.Options.(*protocol.CredentialCreation).Response, passkeyOrigin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := service.FinishRegistration(user.ID, ceremony.SessionID, "Laptop", response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return credential
+}
+
+// loginWithPasskey runs a sign-in with the authenticator and returns the result of FinishLogin.
+func loginWithPasskey(t *testing.T, service *WebAuthnService, email string, authenticator *softauthn.Authenticator) (*models.User, string, error) {
+	t.Helper()
+	ceremony, err := service.BeginLogin(email, "Dispatch desk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := authenticator.GetAssertion(ceremony.Options.(*protocol.CredentialAssertion).Response, passkeyOrigin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service.FinishLogin(ceremony.SessionID, response)
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	db := testdb.Open(t)
+	service := NewWebAuthnService(db)
+	user := createUser(t, db)
+	authenticator := softauthn.New()
+
+	credential := registerPasskey(t, service, user, authenticator)
+	if credential.Name != "Laptop" || credential.UserID != user.ID {
+		t.Fatalf("got passkey %q of %s, want Laptop of %s", credential.Name, credential.UserID, user.ID)
+	}
+
+	// The authenticator holding the passkey is excluded from another registration.
+	ceremony, err := service.BeginRegistration(user.ID, testPassword, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = authenticator.CreateCredential(ceremony.Options.(*protocol.CredentialCreation).Response, passkeyOrigin()); err == nil {
+		t.Fatal("the authenticator registered a second passkey, want it excluded")
+	}
+}
+
+func TestPasskeyRegistrationRequiresReauthentication(t *testing.T) {
+	db := testdb.Open(t)
+	service := NewWebAuthnService(db)
+	user := createUser(t, db)
+
+	if _, err := service.BeginRegistration(user.ID, "wrong password", ""); err != errors.ErrInvalidCredentials {
+		t.Fatalf("wrong password: got %v, want ErrInvalidCredentials", err)
+	}
+
+	confirmedAt := time.Now()
+	if err := db.Create(&models.TOTPFactor{UserID: user.ID, Secret: testTOTPSecret, ConfirmedAt: &confirmedAt}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.BeginRegistration(user.ID, testPassword, ""); err != errors.ErrMFARequired {
+		t.Fatalf("missing code: got %v, want ErrMFARequired", err)
+	}
+	if _, err := service.BeginRegistration(user.ID, testPassword, "000000"); err != errors.ErrInvalidMFACode {
+		t.Fatalf("wrong code: got %v, want ErrInvalidMFACode", err)
+	}
+	if _, err := service.BeginRegistration(user.ID, testPassword, currentTOTPCode(t)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPasskeyLoginWithEmail(t *testing.T) {
+	db := testdb.Open(t)
+	service := NewWebAuthnService(db)
+	user := createUser(t, db)
+	authenticator := softauthn.New()
+	registerPasskey(t, service, user, authenticator)
+
+	ceremony, err := service.BeginLogin(user.Email, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed := ceremony.Options.(*protocol.CredentialAssertion).Response.AllowedCredentials; len(allowed) != 1 {
+		t.Fatalf("got %d allowed passkeys, want the user's one", len(allowed))
+	}
+
+	signedIn, deviceName, err := loginWithPasskey(t, service, user.Email, authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedIn.ID != user.ID || deviceName != "Dispatch desk" {
+		t.Fatalf("got %s on %q, want %s on Dispatch desk", signedIn.ID, deviceName, user.ID)
+	}
+	var stored models.WebAuthnCredential
+	if err = db.Where("user_id = ?", user.ID).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.SignCount != 1 || stored.LastUsedAt == nil {
+		t.Fatalf("got sign count %d and last use %v, want the sign-in recorded", stored.SignCount, stored.LastUsedAt)
+	}
+}
+
+func TestPasskeyDiscoverableLogin(t *testing.T) {
+	db := testdb.Open(t)
+	service := NewWebAuthnService(db)
+	user := createUser(t, db)
+	authenticator := softauthn.New()
+	registerPasskey(t, service, user, authenticator)
+
+	// An unknown email gets the same ceremony as no email at all.
+	for _, email := range []string{"", "nobody@example.com"} {
+		ceremony, err := service.BeginLogin(email, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed := ceremony.Options.(*protocol.CredentialAssertion).Response.AllowedCredentials; len(allowed) != 0 {
+			t.Fatalf("email %q: got %d allowed passkeys, want none", email, len(allowed))
+		}
+	}
+
+	signedIn, _, err := loginWithPasskey(t, service, "", authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedIn.ID != user.ID {
+		t.Fatalf("got user %s, want %s", signedIn.ID, user.ID)
+	}
+}
+
+func TestPasskeyCloneWarning(t *testing.T) {
+	db := testdb.Open(t)
+	service := NewWebAuthnService(db)
+	user := createUser(t, db)
+	authenticator := softauthn.New()
+	registerPasskey(t, service, user, authenticator)
+
+	// Copy the passkey before it signs in, so the copy's counter falls behind the original's.
+	original := authenticator.Credentials()[0]
+	copied := *original
+	clone := softauthn.New()
+	clone.AddCredential(&copied)
+
+	if _, _, err := loginWithPasskey(t, service, user.Email, authenticator); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loginWithPasskey(t, service, user.Email, clone); err != errors.ErrPasskeyCloneWarning {
+		t.Fatalf("cloned passkey: got %v, want ErrPasskeyCloneWarning", err)
+	}
+
+	var stored models.WebAuthnCredential
+	if err := db.Where("user_id = ?", user.ID).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !stored.CloneWarning {
+		t.Fatal("the passkey was not flagged")
+	}
+	var events int64
+	db.Model(&models.SecurityEvent{}).Where("type = ?", models.SecurityEventPasskeyCloneWarning).Count(&events)
+	if events != 1 {
+		t.Fatalf("got %d clone warning events, want 1", events)
+	}
+
+	// Once flagged the passkey is refused, whichever authenticator presents it.
+	if _, _, err := loginWithPasskey(t, service, user.Email, authenticator); err != errors.ErrPasskeyCloneWarning {
+		t.Fatalf("flagged passkey: got %v, want ErrPasskeyCloneWarning", err)
+	}
+}
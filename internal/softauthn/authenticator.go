@@ -0,0 +1,230 @@
+// Package softauthn is a software WebAuthn authenticator. It answers the options of the
+// passkey endpoints like a browser with a platform authenticator would, so registration and
+// sign-in can be driven from scripts and tests without a browser or security key.
+// Its keys live in memory and it attests with "none", it must never stand in for a real user.
+package softauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+// Authenticator data flags, see https://www.w3.org/TR/webauthn-2/#flags.
+const (
+	flagUserPresent            byte = 0x01
+	flagUserVerified           byte = 0x04
+	flagAttestedCredentialData byte = 0x40
+)
+
+var ErrNoCredential = errors.New("softauthn: no credential for the relying party")
+
+// Credential is a passkey held by the authenticator.
+type Credential struct {
+	ID         []byte
+	RPID       string
+	UserHandle []byte
+	PrivateKey *ecdsa.PrivateKey
+	SignCount  uint32
+}
+
+// Authenticator keeps its passkeys in memory. Every signature increases the sign counter of
+// the passkey, like hardware authenticators do.
+type Authenticator struct {
+	AAGUID      [16]byte
+	credentials []*Credential
+}
+
+func New() *Authenticator {
+	return &Authenticator{}
+}
+
+// Credentials returns the passkeys created so far, e.g. to copy one and provoke a clone warning.
+func (a *Authenticator) Credentials() []*Credential {
+	return a.credentials
+}
+
+// AddCredential imports a passkey, e.g. one copied from another authenticator.
+func (a *Authenticator) AddCredential(credential *Credential) {
+	a.credentials = append(a.credentials, credential)
+}
+
+// CreateCredential answers navigator.credentials.create options for the given origin and returns
+// the PublicKeyCredential JSON to send to the relying party.
+func (a *Authenticator) CreateCredential(options protocol.PublicKeyCredentialCreationOptions, origin string) ([]byte, error) {
+	for _, excluded := range options.CredentialExcludeList {
+		if a.find(options.RelyingParty.ID, excluded.CredentialID) != nil {
+			return nil, errors.New("softauthn: the authenticator already holds an excluded credential")
+		}
+	}
+	userHandle, err := userHandleBytes(options.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 32)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+	credential := &Credential{
+		ID:         id,
+		RPID:       options.RelyingParty.ID,
+		UserHandle: userHandle,
+		PrivateKey: privateKey,
+	}
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: credential.PrivateKey.X.FillBytes(make([]byte, 32)),
+		YCoord: credential.PrivateKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var attestedData bytes.Buffer
+	attestedData.Write(a.AAGUID[:])
+	_ = binary.Write(&attestedData, binary.BigEndian, uint16(len(id)))
+	attestedData.Write(id)
+	attestedData.Write(publicKey)
+	authData := authenticatorData(credential, flagAttestedCredentialData, attestedData.Bytes())
+
+	attestationObject, err := webauthncbor.Marshal(struct {
+		Format    string                 `json:"fmt"`
+		Statement map[string]interface{} `json:"attStmt"`
+		AuthData  []byte                 `json:"authData"`
+	}{
+		Format:    "none",
+		Statement: map[string]interface{}{},
+		AuthData:  authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	clientData, err := clientDataJSON(protocol.CreateCeremony, options.Challenge, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials = append(a.credentials, credential)
+	return json.Marshal(map[string]interface{}{
+		"id":                      encode(id),
+		"rawId":                   encode(id),
+		"type":                    string(protocol.PublicKeyCredentialType),
+		"authenticatorAttachment": string(protocol.Platform),
+		"response": map[string]interface{}{
+			"clientDataJSON":    encode(clientData),
+			"attestationObject": encode(attestationObject),
+			"transports":        []string{string(protocol.Internal)},
+		},
+	})
+}
+
+// GetAssertion answers navigator.credentials.get options for the given origin and returns the
+// PublicKeyCredential JSON to send to the relying party. Without allowed credentials in the
+// options the first passkey for the relying party is used.
+func (a *Authenticator) GetAssertion(options protocol.PublicKeyCredentialRequestOptions, origin string) ([]byte, error) {
+	var credential *Credential
+	if len(options.AllowedCredentials) == 0 {
+		credential = a.find(options.RelyingPartyID, nil)
+	}
+	for _, allowed := range options.AllowedCredentials {
+		if credential = a.find(options.RelyingPartyID, allowed.CredentialID); credential != nil {
+			break
+		}
+	}
+	if credential == nil {
+		return nil, ErrNoCredential
+	}
+
+	credential.SignCount++
+	authData := authenticatorData(credential, 0, nil)
+	clientData, err := clientDataJSON(protocol.AssertCeremony, options.Challenge, origin)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, credential.PrivateKey, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"id":                      encode(credential.ID),
+		"rawId":                   encode(credential.ID),
+		"type":                    string(protocol.PublicKeyCredentialType),
+		"authenticatorAttachment": string(protocol.Platform),
+		"response": map[string]interface{}{
+			"clientDataJSON":    encode(clientData),
+			"authenticatorData": encode(authData),
+			"signature":         encode(signature),
+			"userHandle":        encode(credential.UserHandle),
+		},
+	})
+}
+
+// find returns the passkey with the ID for the relying party, or its first one for an empty ID.
+func (a *Authenticator) find(rpID string, id []byte) *Credential {
+	for _, credential := range a.credentials {
+		if credential.RPID == rpID && (id == nil || bytes.Equal(credential.ID, id)) {
+			return credential
+		}
+	}
+	return nil
+}
+
+// authenticatorData lays out the RP ID hash, flags and sign counter, followed by extra data.
+// The user is always reported present and verified.
+func authenticatorData(credential *Credential, flags byte, extra []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(credential.RPID))
+	data := make([]byte, 0, len(rpIDHash)+5+len(extra))
+	data = append(data, rpIDHash[:]...)
+	data = append(data, flags|flagUserPresent|flagUserVerified)
+	data = binary.BigEndian.AppendUint32(data, credential.SignCount)
+	return append(data, extra...)
+}
+
+func clientDataJSON(ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64, origin string) ([]byte, error) {
+	return json.Marshal(protocol.CollectedClientData{
+		Type:      ceremony,
+		Challenge: encode(challenge),
+		Origin:    origin,
+	})
+}
+
+// userHandleBytes reads the user handle of creation options, which are either built in process
+// or decoded from the JSON the relying party sent, where the handle is a base64url string.
+func userHandleBytes(id interface{}) ([]byte, error) {
+	switch value := id.(type) {
+	case protocol.URLEncodedBase64:
+		return value, nil
+	case string:
+		return base64.RawURLEncoding.DecodeString(value)
+	default:
+		return nil, fmt.Errorf("softauthn: unsupported user ID %T", id)
+	}
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
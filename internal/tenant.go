@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type tenantContextKey struct{}
+
+type tenant struct {
+	companyID *uuid.UUID
+}
+
+// TenantScoped is implemented by models whose rows belong to a single company.
+// TenantColumn names the column holding the owning company ID.
+type TenantScoped interface {
+	TenantColumn() string
+}
+
+// WithTenant returns a context that limits repository queries to the given company.
+// A nil company ID limits queries to rows that do not belong to any company.
+func WithTenant(ctx context.Context, companyID *uuid.UUID) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant{companyID: companyID})
+}
+
+// TenantFromContext returns the company carried by the context, if any.
+func TenantFromContext(ctx context.Context) (companyID *uuid.UUID, ok bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	t, ok := ctx.Value(tenantContextKey{}).(tenant)
+	if !ok {
+		return nil, false
+	}
+	return t.companyID, true
+}
+
+// TenantScope limits a query on T to the company carried by the statement context.
+// Queries without a tenant in the context, and models that are not TenantScoped, are left untouched.
+func TenantScope[T any](db *gorm.DB) *gorm.DB {
+	companyID, ok := TenantFromContext(db.Statement.Context)
+	if !ok {
+		return db
+	}
+
+	var model T
+	scoped, ok := any(&model).(TenantScoped)
+	if !ok {
+		return db
+	}
+
+	column := clause.Column{Table: clause.CurrentTable, Name: scoped.TenantColumn()}
+	if companyID == nil {
+		return db.Where(clause.Eq{Column: column, Value: nil})
+	}
+	return db.Where(clause.Eq{Column: column, Value: *companyID})
+}
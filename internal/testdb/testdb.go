@@ -0,0 +1,116 @@
+// Package testdb opens a throwaway in-memory SQLite database with the service's tables, so
+// services and repositories can be tested without a Postgres server.
+package testdb
+
+import (
+	"fleet-pulse-users-service/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+var tables = []interface{}{
+	&models.Company{},
+	&models.Permission{},
+	&models.Role{},
+	&models.User{},
+	&models.Session{},
+	&models.RefreshToken{},
+	&models.RevokedAccessToken{},
+	&models.RevokedSession{},
+	&models.SigningKey{},
+	&models.SecurityEvent{},
+	&models.Invite{},
+	&models.PasswordResetToken{},
+	&models.OutboxMessage{},
+	&models.Client{},
+	&models.AuthorizationCode{},
+	&models.OAuthConsent{},
+	&models.TOTPFactor{},
+	&models.RecoveryCode{},
+	&models.MFAChallenge{},
+	&models.WebAuthnCredential{},
+	&models.WebAuthnSession{},
+}
+
+// Open returns a fresh database that is closed when the test ends.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(
+		sqlite.Open("file:"+uuid.NewString()+"?mode=memory&cache=shared&_foreign_keys=on"),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)},
+	)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	// The database lives as long as its connection, a single one also keeps writes serialized.
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	// Postgres fills in primary keys with uuid_generate_v4() and similar defaults SQLite does
+	// not know, so they are dropped from the schema and generated on create instead.
+	for _, table := range tables {
+		stmt := &gorm.Statement{DB: db}
+		if err = stmt.Parse(table); err != nil {
+			t.Fatalf("parse %T: %v", table, err)
+		}
+		dropFunctionDefaults(stmt.Schema)
+		for _, relationship := range stmt.Schema.Relationships.Relations {
+			if relationship.JoinTable != nil {
+				dropFunctionDefaults(relationship.JoinTable)
+			}
+		}
+	}
+	err = db.Callback().Create().Before("gorm:create").Register("testdb:generate_uuids", generateUUIDs)
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	if err = db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+func dropFunctionDefaults(table *schema.Schema) {
+	for _, field := range table.Fields {
+		if strings.Contains(field.DefaultValue, "(") {
+			field.HasDefaultValue = false
+			field.DefaultValue = ""
+			field.DefaultValueInterface = nil
+		}
+	}
+}
+
+func generateUUIDs(db *gorm.DB) {
+	if db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	if field == nil || field.FieldType != reflect.TypeOf(uuid.UUID{}) {
+		return
+	}
+	ctx := db.Statement.Context
+	assign := func(value reflect.Value) {
+		if _, isZero := field.ValueOf(ctx, value); isZero {
+			db.AddError(field.Set(ctx, value, uuid.New()))
+		}
+	}
+	switch value := reflect.Indirect(db.Statement.ReflectValue); value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			assign(reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		assign(value)
+	}
+}
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS "pgcrypto";
+
+-- Create companies table
+CREATE TABLE companies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Link users to the company they belong to
+ALTER TABLE users
+    ADD COLUMN company_id UUID REFERENCES companies(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_users_company_id ON users(company_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_company_id;
+ALTER TABLE users DROP COLUMN company_id;
+DROP TABLE companies;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Refresh tokens carry the owner's company so they can be tenant-scoped
+ALTER TABLE refresh_tokens
+    ADD COLUMN company_id UUID REFERENCES companies(id) ON DELETE SET NULL;
+
+UPDATE refresh_tokens
+SET company_id = users.company_id
+FROM users
+WHERE users.id = refresh_tokens.user_id;
+
+CREATE INDEX idx_refresh_tokens_company_id ON refresh_tokens(company_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_refresh_tokens_company_id;
+ALTER TABLE refresh_tokens DROP COLUMN company_id;
+-- +goose StatementEnd
@@ -0,0 +1,81 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS "pgcrypto";
+
+CREATE TABLE roles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE TABLE permissions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE TABLE role_permissions (
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id UUID NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX idx_user_roles_role_id ON user_roles(role_id);
+
+-- Seed roles
+INSERT INTO roles (name, description) VALUES
+    ('fleet-admin', 'Manages the company, its users and its fleet'),
+    ('dispatcher', 'Plans trips and assigns vehicles and drivers'),
+    ('driver', 'Drives vehicles and reports on assigned trips'),
+    ('viewer', 'Read-only access to the company fleet');
+
+-- Seed permissions
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'View company users'),
+    ('users:write', 'Add, change and remove company users'),
+    ('companies:read', 'View company details'),
+    ('companies:write', 'Change and delete the company'),
+    ('roles:read', 'View roles and role assignments'),
+    ('roles:write', 'Assign roles to company users'),
+    ('vehicles:read', 'View vehicles'),
+    ('vehicles:write', 'Add, change and remove vehicles'),
+    ('trips:read', 'View trips'),
+    ('trips:write', 'Plan and update trips'),
+    ('telemetry:read', 'View vehicle telemetry');
+
+-- Grant permissions to roles
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON (
+    (r.name = 'fleet-admin')
+    OR (r.name = 'dispatcher' AND p.name IN (
+        'users:read', 'companies:read', 'vehicles:read', 'vehicles:write',
+        'trips:read', 'trips:write', 'telemetry:read'
+    ))
+    OR (r.name = 'driver' AND p.name IN (
+        'vehicles:read', 'trips:read', 'trips:write'
+    ))
+    OR (r.name = 'viewer' AND p.name IN (
+        'users:read', 'companies:read', 'vehicles:read', 'trips:read', 'telemetry:read'
+    ))
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE user_roles;
+DROP TABLE role_permissions;
+DROP TABLE permissions;
+DROP TABLE roles;
+-- +goose StatementEnd
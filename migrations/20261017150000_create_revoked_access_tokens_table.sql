@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE revoked_access_tokens (
+    jti TEXT PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_revoked_access_tokens_expires_at ON revoked_access_tokens(expires_at);
+
+-- Access tokens issued before this moment are rejected
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMPTZ;
+
+CREATE INDEX idx_users_tokens_valid_after ON users(tokens_valid_after) WHERE tokens_valid_after IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_tokens_valid_after;
+ALTER TABLE users DROP COLUMN tokens_valid_after;
+DROP TABLE revoked_access_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE clients
+    -- Space-separated redirect URIs the authorize endpoint may send codes to
+    ADD COLUMN redirect_uris TEXT NOT NULL DEFAULT '',
+    ADD COLUMN public BOOLEAN NOT NULL DEFAULT false;
+
+-- Sessions opened through an OAuth client are limited to the scopes granted to it
+ALTER TABLE sessions
+    ADD COLUMN client_id TEXT REFERENCES clients(client_id) ON DELETE CASCADE,
+    ADD COLUMN scopes TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_sessions_client_id ON sessions(client_id);
+
+CREATE TABLE oauth_consents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id TEXT NOT NULL REFERENCES clients(client_id) ON DELETE CASCADE,
+    scopes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE (user_id, client_id)
+);
+
+CREATE TABLE authorization_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code_hash TEXT NOT NULL UNIQUE,
+    client_id TEXT NOT NULL REFERENCES clients(client_id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL,
+    -- Session opened when the code was exchanged, ended if the code is replayed
+    session_id UUID REFERENCES sessions(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_authorization_codes_expires_at ON authorization_codes(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE authorization_codes;
+DROP TABLE oauth_consents;
+DROP INDEX IF EXISTS idx_sessions_client_id;
+ALTER TABLE sessions DROP COLUMN scopes, DROP COLUMN client_id;
+ALTER TABLE clients DROP COLUMN public, DROP COLUMN redirect_uris;
+-- +goose StatementEnd
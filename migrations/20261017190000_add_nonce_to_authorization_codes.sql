@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- OpenID Connect nonce copied into the ID token issued for the code
+ALTER TABLE authorization_codes ADD COLUMN nonce TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE authorization_codes DROP COLUMN nonce;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Hash of the single use nonce carried by the invite token, cleared on acceptance
+ALTER TABLE invites ADD COLUMN nonce_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN accepted_at TIMESTAMPTZ;
+
+-- Tokens sent before nonces existed cannot be accepted anymore, admins can resend them
+UPDATE invites SET status = 'expired' WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN accepted_at;
+ALTER TABLE invites DROP COLUMN nonce_hash;
+-- +goose StatementEnd
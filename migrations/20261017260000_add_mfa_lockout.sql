@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE totp_factors
+    ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN failure_window_start TIMESTAMPTZ,
+    ADD COLUMN locked_until TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE totp_factors
+    DROP COLUMN locked_until,
+    DROP COLUMN failure_window_start,
+    DROP COLUMN failed_attempts;
+-- +goose StatementEnd
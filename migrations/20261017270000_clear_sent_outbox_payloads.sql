@@ -0,0 +1,8 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Delivered emails carry reset links and invite tokens, the dispatcher now clears them once sent.
+UPDATE outbox SET payload = '{}' WHERE status = 'sent';
+-- +goose StatementEnd
+
+-- +goose Down
+-- The cleared payloads cannot be restored.
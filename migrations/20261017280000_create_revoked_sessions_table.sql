@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Access tokens carrying one of these session IDs are rejected
+CREATE TABLE revoked_sessions (
+    session_id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_revoked_sessions_expires_at ON revoked_sessions(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE revoked_sessions;
+-- +goose StatementEnd